@@ -0,0 +1,219 @@
+package propagator
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ============================================================================
+// Token Validation Cache
+// ============================================================================
+
+// Defaults applied by NewCachingAuthService
+const (
+	DefaultTokenTTL          = 5 * time.Minute
+	DefaultNegativeTokenTTL  = 10 * time.Second
+	DefaultValidationTimeout = 10 * time.Second
+)
+
+// ExpiringAuthService is implemented by AuthServices that know when a token expires
+// CachingAuthService uses it to cache a token for exactly as long as it is valid
+type ExpiringAuthService interface {
+	AuthService
+
+	// ValidateTokenExpiry validates the token like ValidateToken and also
+	// returns the time at which it expires
+	ValidateTokenExpiry(ctx context.Context, token string) (userID string, expiresAt time.Time, err error)
+}
+
+// CachingAuthService is an AuthService decorator that caches validation results
+// Valid tokens are cached until they expire, or for the TTL when the wrapped
+// service does not report expiry. ErrInvalidToken is cached briefly so a bad
+// token cannot hammer the backend, and ErrTokenExpired evicts the token.
+// Other failures are not cached. Concurrent validations of the same token
+// share a single backend call. It is safe for concurrent use
+type CachingAuthService struct {
+	next              AuthService
+	ttl               time.Duration
+	negativeTTL       time.Duration
+	validationTimeout time.Duration
+	now               func() time.Time
+
+	group     singleflight.Group
+	mu        sync.Mutex
+	entries   map[[sha256.Size]byte]tokenEntry
+	nextSweep time.Time
+}
+
+// tokenEntry is a cached validation result
+type tokenEntry struct {
+	userID    string
+	err       error // Non-nil for negative entries
+	expiresAt time.Time
+}
+
+// validation is the result of one shared backend call
+type validation struct {
+	userID    string
+	expiresAt time.Time
+}
+
+// AuthCacheOption configures a CachingAuthService
+type AuthCacheOption func(*CachingAuthService)
+
+// WithTokenTTL sets how long a valid token is cached when its expiry is unknown
+func WithTokenTTL(d time.Duration) AuthCacheOption {
+	return func(c *CachingAuthService) {
+		c.ttl = d
+	}
+}
+
+// WithNegativeTokenTTL sets how long ErrInvalidToken is cached
+// Zero disables negative caching
+func WithNegativeTokenTTL(d time.Duration) AuthCacheOption {
+	return func(c *CachingAuthService) {
+		c.negativeTTL = d
+	}
+}
+
+// WithValidationTimeout bounds a shared backend call
+// The call is detached from any single caller's cancellation, since other
+// callers may be waiting on it, so it needs its own limit
+func WithValidationTimeout(d time.Duration) AuthCacheOption {
+	return func(c *CachingAuthService) {
+		c.validationTimeout = d
+	}
+}
+
+// WithAuthCacheClock replaces time.Now, for tests
+func WithAuthCacheClock(now func() time.Time) AuthCacheOption {
+	return func(c *CachingAuthService) {
+		c.now = now
+	}
+}
+
+// NewCachingAuthService wraps next with a validation cache
+func NewCachingAuthService(next AuthService, opts ...AuthCacheOption) *CachingAuthService {
+	c := &CachingAuthService{
+		next:              next,
+		ttl:               DefaultTokenTTL,
+		negativeTTL:       DefaultNegativeTokenTTL,
+		validationTimeout: DefaultValidationTimeout,
+		now:               time.Now,
+		entries:           make(map[[sha256.Size]byte]tokenEntry),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ValidateToken returns the cached result for token, validating it with the
+// wrapped service on a miss
+// A caller whose ctx ends while waiting on a shared call returns ctx.Err()
+// without affecting the other callers
+func (c *CachingAuthService) ValidateToken(ctx context.Context, token string) (string, error) {
+	// Tokens are keyed by hash so the cache never holds credentials
+	key := sha256.Sum256([]byte(token))
+	if e, ok := c.lookup(key); ok {
+		return e.userID, e.err
+	}
+
+	ch := c.group.DoChan(string(key[:]), func() (any, error) {
+		callCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.validationTimeout)
+		defer cancel()
+		v, err := c.validate(callCtx, token)
+		c.store(key, v, err)
+		return v, err
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return "", res.Err
+		}
+		return res.Val.(validation).userID, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Invalidate drops any cached result for token, e.g., after logout
+func (c *CachingAuthService) Invalidate(token string) {
+	key := sha256.Sum256([]byte(token))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// validate calls the wrapped service, asking for the expiry when it can report it
+func (c *CachingAuthService) validate(ctx context.Context, token string) (validation, error) {
+	if exp, ok := c.next.(ExpiringAuthService); ok {
+		userID, expiresAt, err := exp.ValidateTokenExpiry(ctx, token)
+		return validation{userID: userID, expiresAt: expiresAt}, err
+	}
+	userID, err := c.next.ValidateToken(ctx, token)
+	return validation{userID: userID}, err
+}
+
+// lookup returns the unexpired entry for key
+func (c *CachingAuthService) lookup(key [sha256.Size]byte) (tokenEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return tokenEntry{}, false
+	}
+	if !c.now().Before(e.expiresAt) {
+		delete(c.entries, key)
+		return tokenEntry{}, false
+	}
+	return e, true
+}
+
+// store records the outcome of a backend call
+func (c *CachingAuthService) store(key [sha256.Size]byte, v validation, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.sweep(now)
+
+	var e tokenEntry
+	switch {
+	case err == nil:
+		e = tokenEntry{userID: v.userID, expiresAt: v.expiresAt}
+		if e.expiresAt.IsZero() {
+			e.expiresAt = now.Add(c.ttl)
+		}
+	case errors.Is(err, ErrInvalidToken) && c.negativeTTL > 0:
+		e = tokenEntry{err: err, expiresAt: now.Add(c.negativeTTL)}
+	default:
+		// Expired tokens are evicted; anything else may succeed on the next call
+		delete(c.entries, key)
+		return
+	}
+
+	if now.Before(e.expiresAt) {
+		c.entries[key] = e
+	}
+}
+
+// sweep drops expired entries at most once per TTL so the map stays bounded
+// by the number of live tokens; c.mu must be held
+func (c *CachingAuthService) sweep(now time.Time) {
+	if now.Before(c.nextSweep) {
+		return
+	}
+	for key, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	c.nextSweep = now.Add(c.ttl)
+}
@@ -0,0 +1,232 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// expiringAuthService reports token expiry and can hold calls until released
+type expiringAuthService struct {
+	calls     atomic.Int32
+	expiresAt time.Time
+	err       error
+	release   chan struct{}
+}
+
+func (m *expiringAuthService) ValidateToken(ctx context.Context, token string) (string, error) {
+	userID, _, err := m.ValidateTokenExpiry(ctx, token)
+	return userID, err
+}
+
+func (m *expiringAuthService) ValidateTokenExpiry(ctx context.Context, token string) (string, time.Time, error) {
+	m.calls.Add(1)
+	if m.release != nil {
+		<-m.release
+	}
+	if m.err != nil {
+		return "", time.Time{}, m.err
+	}
+	return "user-" + token, m.expiresAt, nil
+}
+
+// ============================================================================
+// Token Cache Tests
+// ============================================================================
+
+func TestCachingAuthService_CachesUntilExpiry(t *testing.T) {
+	clock := newFakeClock()
+	backend := &expiringAuthService{expiresAt: clock.Now().Add(time.Hour)}
+	cache := NewCachingAuthService(backend, WithAuthCacheClock(clock.Now), WithTokenTTL(time.Minute))
+	ctx := context.Background()
+
+	for range 3 {
+		userID, err := cache.ValidateToken(ctx, "tok")
+		if err != nil || userID != "user-tok" {
+			t.Fatalf("ValidateToken() = %q, %v", userID, err)
+		}
+	}
+	// The known expiry wins over the shorter TTL
+	clock.Advance(59 * time.Minute)
+	if _, err := cache.ValidateToken(ctx, "tok"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := backend.calls.Load(); got != 1 {
+		t.Errorf("expected 1 backend call before expiry, got %d", got)
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := cache.ValidateToken(ctx, "tok"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := backend.calls.Load(); got != 2 {
+		t.Errorf("expected revalidation at expiry, got %d calls", got)
+	}
+}
+
+func TestCachingAuthService_FallsBackToTTL(t *testing.T) {
+	clock := newFakeClock()
+	backend := &countingAuthService{}
+	cache := NewCachingAuthService(backend, WithAuthCacheClock(clock.Now), WithTokenTTL(time.Minute))
+	ctx := context.Background()
+
+	_, _ = cache.ValidateToken(ctx, "tok")
+	clock.Advance(30 * time.Second)
+	_, _ = cache.ValidateToken(ctx, "tok")
+	clock.Advance(30 * time.Second)
+	_, _ = cache.ValidateToken(ctx, "tok")
+
+	if got := backend.calls.Load(); got != 2 {
+		t.Errorf("expected 2 backend calls across one TTL boundary, got %d", got)
+	}
+}
+
+func TestCachingAuthService_DeduplicatesConcurrentValidations(t *testing.T) {
+	backend := &expiringAuthService{release: make(chan struct{})}
+	cache := NewCachingAuthService(backend)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Go(func() {
+			_, err := cache.ValidateToken(context.Background(), "tok")
+			errs <- err
+		})
+	}
+	// Give every caller a chance to join the in-flight call
+	for backend.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(backend.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if got := backend.calls.Load(); got != 1 {
+		t.Errorf("expected a single backend call, got %d", got)
+	}
+}
+
+func TestCachingAuthService_WaiterCancellationIsIndependent(t *testing.T) {
+	backend := &expiringAuthService{release: make(chan struct{})}
+	cache := NewCachingAuthService(backend)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := cache.ValidateToken(context.Background(), "tok")
+		done <- err
+	}()
+	for backend.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cache.ValidateToken(ctx, "tok"); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled waiter should return context.Canceled, got: %v", err)
+	}
+
+	close(backend.release)
+	if err := <-done; err != nil {
+		t.Errorf("other waiters must not see the cancellation, got: %v", err)
+	}
+}
+
+func TestCachingAuthService_NegativeCachesInvalidToken(t *testing.T) {
+	clock := newFakeClock()
+	backend := &expiringAuthService{err: &AuthError{Op: "validate_token", Err: ErrInvalidToken}}
+	cache := NewCachingAuthService(backend, WithAuthCacheClock(clock.Now), WithNegativeTokenTTL(5*time.Second))
+	ctx := context.Background()
+
+	for range 3 {
+		_, err := cache.ValidateToken(ctx, "bad")
+		var authErr *AuthError
+		if !errors.As(err, &authErr) || !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("expected the cached AuthError, got: %v", err)
+		}
+	}
+	if got := backend.calls.Load(); got != 1 {
+		t.Errorf("invalid token should be cached, got %d calls", got)
+	}
+
+	clock.Advance(5 * time.Second)
+	_, _ = cache.ValidateToken(ctx, "bad")
+	if got := backend.calls.Load(); got != 2 {
+		t.Errorf("negative entry should expire, got %d calls", got)
+	}
+}
+
+func TestCachingAuthService_DoesNotCacheTemporaryFailures(t *testing.T) {
+	backend := &expiringAuthService{err: NewAuthError("validate_token", "", "", ErrAuthFailed, AsTemporary())}
+	cache := NewCachingAuthService(backend)
+
+	_, _ = cache.ValidateToken(context.Background(), "tok")
+	_, err := cache.ValidateToken(context.Background(), "tok")
+
+	if !IsTemporary(err) {
+		t.Errorf("expected the temporary error, got: %v", err)
+	}
+	if got := backend.calls.Load(); got != 2 {
+		t.Errorf("temporary failures must not be cached, got %d calls", got)
+	}
+}
+
+func TestCachingAuthService_ExpiredTokenIsNotCached(t *testing.T) {
+	backend := &expiringAuthService{err: &AuthError{Op: "validate_token", Err: ErrTokenExpired}}
+	cache := NewCachingAuthService(backend)
+
+	for range 2 {
+		if _, err := cache.ValidateToken(context.Background(), "tok"); !errors.Is(err, ErrTokenExpired) {
+			t.Fatalf("expected ErrTokenExpired, got: %v", err)
+		}
+	}
+	if got := backend.calls.Load(); got != 2 {
+		t.Errorf("expired tokens must not be cached, got %d calls", got)
+	}
+	if len(cache.entries) != 0 {
+		t.Errorf("expected an empty cache, got %d entries", len(cache.entries))
+	}
+}
+
+func TestCachingAuthService_Invalidate(t *testing.T) {
+	backend := &countingAuthService{}
+	cache := NewCachingAuthService(backend)
+
+	_, _ = cache.ValidateToken(context.Background(), "tok")
+	cache.Invalidate("tok")
+	_, _ = cache.ValidateToken(context.Background(), "tok")
+
+	if got := backend.calls.Load(); got != 2 {
+		t.Errorf("invalidated token should be revalidated, got %d calls", got)
+	}
+}
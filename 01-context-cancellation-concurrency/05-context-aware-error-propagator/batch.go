@@ -0,0 +1,175 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// ============================================================================
+// Batch Uploads
+// ============================================================================
+
+// DefaultBatchConcurrency bounds in-flight uploads when no limit is configured
+const DefaultBatchConcurrency = 4
+
+// ErrBatchAborted marks files that were never attempted because a fail-fast batch stopped
+var ErrBatchAborted = NewSentinel(CodeBatchAborted, "batch aborted before file was uploaded")
+
+// UploadResult is the outcome of one file in a batch
+type UploadResult struct {
+	Index    int    // Position of the request in the batch
+	FileName string // Sanitized FileName the record is created with
+	FileID   string // Metadata file ID; empty on failure
+	Key      string // Object key the content was stored under; empty on failure
+	Err      error  // Nil on success
+}
+
+// BatchOption configures UploadFiles
+type BatchOption func(*batchConfig)
+
+type batchConfig struct {
+	concurrency    int
+	perFileTimeout time.Duration
+	failFast       bool
+}
+
+// WithBatchConcurrency limits how many files are uploaded at once
+func WithBatchConcurrency(n int) BatchOption {
+	return func(c *batchConfig) {
+		c.concurrency = n
+	}
+}
+
+// WithPerFileTimeout bounds the time spent on each file, independently of the batch context
+func WithPerFileTimeout(d time.Duration) BatchOption {
+	return func(c *batchConfig) {
+		c.perFileTimeout = d
+	}
+}
+
+// WithFailFast stops the batch at the first failure
+// In-flight uploads are cancelled and files not yet started fail with ErrBatchAborted
+// Without it the batch is best-effort and every file is attempted
+func WithFailFast() BatchOption {
+	return func(c *batchConfig) {
+		c.failFast = true
+	}
+}
+
+// UploadFiles uploads many files with bounded concurrency
+// Each distinct token is validated once for the whole batch and each file is
+// checked against the Policy (if any) before it is uploaded. The returned
+// slice has one result per request, in request order; the error joins every
+// per-file failure (wrapped with the file's index and name) and is nil only
+// if all files were uploaded
+func (g *CloudStorageGateway) UploadFiles(ctx context.Context, reqs []FileUploadRequest, opts ...BatchOption) ([]UploadResult, error) {
+	cfg := batchConfig{concurrency: DefaultBatchConcurrency}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.concurrency <= 0 {
+		cfg.concurrency = DefaultBatchConcurrency
+	}
+
+	results := make([]UploadResult, len(reqs))
+	for i, req := range reqs {
+		results[i] = UploadResult{Index: i, FileName: SanitizeFileName(req.FileName)}
+	}
+
+	// 1. Validate every distinct token once
+	users, authErrs := g.validateTokens(ctx, reqs)
+
+	// 2. Upload with bounded concurrency
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(cfg.concurrency)
+	for i, req := range reqs {
+		if cfg.failFast && egCtx.Err() != nil {
+			results[i].Err = ErrBatchAborted
+			continue
+		}
+		if err := authErrs[req.Token]; err != nil {
+			results[i].Err = WrapWithContext(err, "upload failed: auth")
+			if cfg.failFast {
+				eg.Go(func() error { return results[i].Err })
+			}
+			continue
+		}
+
+		userID := users[req.Token]
+		if err := g.authorize(ctx, userID, ActionUpload, req.Bucket); err != nil {
+			results[i].Err = WrapWithContext(err, "upload failed: permission")
+			if cfg.failFast {
+				eg.Go(func() error { return results[i].Err })
+			}
+			continue
+		}
+
+		eg.Go(func() error {
+			// eg.Go blocks at the concurrency limit, so a failure may have
+			// happened between the check above and this goroutine starting
+			if cfg.failFast && egCtx.Err() != nil {
+				results[i].Err = ErrBatchAborted
+				return nil
+			}
+			// Best-effort files must not be cancelled by each other
+			parent := ctx
+			if cfg.failFast {
+				parent = egCtx
+			}
+			fileCtx, cancel := withOptionalTimeout(parent, cfg.perFileTimeout)
+			defer cancel()
+
+			fileID, key, err := g.upload(fileCtx, userID, req)
+			results[i].FileID, results[i].Key, results[i].Err = fileID, key, err
+			if cfg.failFast {
+				return err
+			}
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, WrapWithContext(r.Err, "file %d (%s)", r.Index, r.FileName))
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// validateTokens validates each distinct token in reqs once
+func (g *CloudStorageGateway) validateTokens(ctx context.Context, reqs []FileUploadRequest) (map[string]string, map[string]error) {
+	users := make(map[string]string)
+	errs := make(map[string]error)
+	for _, req := range reqs {
+		if _, seen := users[req.Token]; seen {
+			continue
+		}
+		if _, seen := errs[req.Token]; seen {
+			continue
+		}
+		var userID string
+		err := g.step(ctx, g.timeouts.Auth, func(ctx context.Context) (err error) {
+			userID, err = g.auth.ValidateToken(ctx, req.Token)
+			return err
+		})
+		if err != nil {
+			errs[req.Token] = err
+			continue
+		}
+		users[req.Token] = userID
+	}
+	return users, errs
+}
+
+// withOptionalTimeout derives a cancellable context, bounded by d when d is positive
+func withOptionalTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
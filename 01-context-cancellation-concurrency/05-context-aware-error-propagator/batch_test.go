@@ -0,0 +1,237 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingAuthService counts validations and rejects a configured token
+type countingAuthService struct {
+	calls    atomic.Int32
+	badToken string
+}
+
+func (m *countingAuthService) ValidateToken(ctx context.Context, token string) (string, error) {
+	m.calls.Add(1)
+	if token == m.badToken {
+		return "", &AuthError{Op: "validate_token", Err: ErrInvalidToken}
+	}
+	return "user-" + token, nil
+}
+
+// batchStorageService is safe for concurrent use and fails or stalls selected keys
+type batchStorageService struct {
+	mockStorageService
+	fail     map[string]error
+	slow     map[string]bool
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	uploaded []string
+}
+
+func (m *batchStorageService) UploadFile(ctx context.Context, bucket, key string, data []byte) error {
+	m.mu.Lock()
+	m.inFlight++
+	m.peak = max(m.peak, m.inFlight)
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.inFlight--
+		m.mu.Unlock()
+	}()
+
+	delay := time.Millisecond
+	if m.slow[key] {
+		delay = time.Minute
+	}
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return &StorageError{Op: "upload", Bucket: bucket, Key: key, Err: ctx.Err(), isTimeout: errors.Is(ctx.Err(), context.DeadlineExceeded)}
+	}
+
+	if err := m.fail[key]; err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.uploaded = append(m.uploaded, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func batchRequests(n int, token string) []FileUploadRequest {
+	reqs := make([]FileUploadRequest, n)
+	for i := range reqs {
+		reqs[i] = FileUploadRequest{
+			Token:    token,
+			FileName: fmt.Sprintf("file-%d.txt", i),
+			Bucket:   "my-bucket",
+			Data:     []byte("hello world"),
+			Key:      fmt.Sprintf("key-%d", i),
+		}
+	}
+	return reqs
+}
+
+// ============================================================================
+// Batch Upload Tests
+// ============================================================================
+
+func TestUploadFiles_ValidatesTokenOnceAndBoundsConcurrency(t *testing.T) {
+	auth := &countingAuthService{}
+	storage := &batchStorageService{}
+	gateway := NewCloudStorageGateway(auth, &mockMetadataService{fileID: "file456"}, storage)
+
+	results, err := gateway.UploadFiles(context.Background(), batchRequests(10, "tok"), WithBatchConcurrency(3))
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := auth.calls.Load(); got != 1 {
+		t.Errorf("expected a single token validation, got %d", got)
+	}
+	if storage.peak > 3 {
+		t.Errorf("expected at most 3 concurrent uploads, saw %d", storage.peak)
+	}
+	for i, r := range results {
+		if r.Index != i || r.Err != nil || r.Key != ClientObjectKey("user-tok", fmt.Sprintf("key-%d", i)) || r.FileID != "file456" {
+			t.Errorf("unexpected result %d: %+v", i, r)
+		}
+	}
+}
+
+func TestUploadFiles_BestEffortReportsEveryFailure(t *testing.T) {
+	quotaErr := &StorageQuotaError{Bucket: "my-bucket", CurrentUsage: 10, Limit: 5, Err: ErrQuotaExceeded}
+	storage := &batchStorageService{fail: map[string]error{
+		ClientObjectKey("user-tok", "key-1"): quotaErr,
+		ClientObjectKey("user-tok", "key-3"): &StorageError{Op: "upload", Err: ErrStorageUnavailable, isTemp: true},
+	}}
+	gateway := NewCloudStorageGateway(&countingAuthService{}, &mockMetadataService{fileID: "file456"}, storage)
+
+	results, err := gateway.UploadFiles(context.Background(), batchRequests(5, "tok"))
+
+	if len(storage.uploaded) != 3 {
+		t.Errorf("best-effort mode should upload every other file, got %v", storage.uploaded)
+	}
+	if results[1].Err == nil || results[3].Err == nil || results[0].Err != nil {
+		t.Errorf("per-file results should reflect individual outcomes: %+v", results)
+	}
+
+	var extracted *StorageQuotaError
+	if !errors.As(err, &extracted) || extracted.Limit != 5 {
+		t.Errorf("summary should expose the quota error, got: %v", err)
+	}
+	if !errors.Is(err, ErrStorageUnavailable) {
+		t.Error("summary should expose every failure")
+	}
+	if !IsTemporary(err) || ClassifyTemporary(err, AllBranches).Matched {
+		t.Error("a mixed batch is temporary for some files but not all")
+	}
+}
+
+func TestUploadFiles_FailFastAbortsRemainingFiles(t *testing.T) {
+	storage := &batchStorageService{
+		fail: map[string]error{ClientObjectKey("user-tok", "key-0"): &StorageError{Op: "upload", Err: errors.New("permanent")}},
+		slow: map[string]bool{ClientObjectKey("user-tok", "key-1"): true},
+	}
+	gateway := NewCloudStorageGateway(&countingAuthService{}, &mockMetadataService{fileID: "file456"}, storage)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	results, err := gateway.UploadFiles(ctx, batchRequests(20, "tok"), WithBatchConcurrency(2), WithFailFast())
+
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if ctx.Err() != nil {
+		t.Fatal("fail-fast should cancel the slow upload instead of waiting for it")
+	}
+	if !errors.Is(results[1].Err, context.Canceled) {
+		t.Errorf("in-flight upload should be cancelled, got: %v", results[1].Err)
+	}
+	if !errors.Is(results[19].Err, ErrBatchAborted) {
+		t.Errorf("files after the failure should be aborted, got: %v", results[19].Err)
+	}
+}
+
+func TestUploadFiles_FailFastWithConcurrencyOne(t *testing.T) {
+	storage := &batchStorageService{fail: map[string]error{ClientObjectKey("user-tok", "key-0"): &StorageError{Op: "upload", Err: errors.New("permanent")}}}
+	gateway := NewCloudStorageGateway(&countingAuthService{}, &mockMetadataService{fileID: "file456"}, storage)
+
+	results, err := gateway.UploadFiles(context.Background(), batchRequests(3, "tok"), WithBatchConcurrency(1), WithFailFast())
+
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	for _, r := range results[1:] {
+		if !errors.Is(r.Err, ErrBatchAborted) {
+			t.Errorf("file %d queued behind the failure should be aborted, got: %v", r.Index, r.Err)
+		}
+	}
+	if len(storage.uploaded) != 0 {
+		t.Errorf("nothing should be uploaded after the failure, got %v", storage.uploaded)
+	}
+}
+
+func TestUploadFiles_PerFileTimeout(t *testing.T) {
+	storage := &batchStorageService{slow: map[string]bool{ClientObjectKey("user-tok", "key-0"): true}}
+	gateway := NewCloudStorageGateway(&countingAuthService{}, &mockMetadataService{fileID: "file456"}, storage)
+
+	results, err := gateway.UploadFiles(context.Background(), batchRequests(2, "tok"), WithPerFileTimeout(20*time.Millisecond))
+
+	if !IsTimeout(results[0].Err) {
+		t.Errorf("slow file should time out, got: %v", results[0].Err)
+	}
+	if results[1].Err != nil {
+		t.Errorf("other files are unaffected by one file's timeout, got: %v", results[1].Err)
+	}
+	if !IsTimeout(err) {
+		t.Error("summary should report the timeout")
+	}
+}
+
+func TestUploadFiles_ReportsSanitizedNames(t *testing.T) {
+	gateway := NewCloudStorageGateway(&countingAuthService{}, &mockMetadataService{fileID: "file456"}, &batchStorageService{})
+	req := FileUploadRequest{Token: "tok", FileName: "../etc/report.txt", Bucket: "my-bucket", Data: []byte("hello")}
+
+	results, err := gateway.UploadFiles(context.Background(), []FileUploadRequest{req})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	single, err := gateway.UploadFileResult(context.Background(), req)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if results[0].FileName != single.FileName || results[0].FileName != SanitizeFileName(req.FileName) {
+		t.Errorf("batch and single uploads should report the same sanitized name, got %q and %q", results[0].FileName, single.FileName)
+	}
+}
+
+func TestUploadFiles_InvalidTokenFailsOnlyItsFiles(t *testing.T) {
+	auth := &countingAuthService{badToken: "bad"}
+	gateway := NewCloudStorageGateway(auth, &mockMetadataService{fileID: "file456"}, &batchStorageService{})
+
+	reqs := append(batchRequests(2, "good"), batchRequests(2, "bad")...)
+	results, err := gateway.UploadFiles(context.Background(), reqs)
+
+	if got := auth.calls.Load(); got != 2 {
+		t.Errorf("expected one validation per distinct token, got %d", got)
+	}
+	if results[0].Err != nil || results[1].Err != nil {
+		t.Errorf("files with a valid token should succeed: %+v", results[:2])
+	}
+	if !errors.Is(results[2].Err, ErrInvalidToken) || !errors.Is(results[3].Err, ErrInvalidToken) {
+		t.Errorf("files with an invalid token should fail auth: %+v", results[2:])
+	}
+	var authErr *AuthError
+	if !errors.As(err, &authErr) {
+		t.Errorf("summary should expose the AuthError, got: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package propagator
+
+import (
+	"context"
+	"time"
+)
+
+// ============================================================================
+// Step Budgets
+// ============================================================================
+
+// DefaultCleanupTimeout bounds compensations unless StepTimeouts.Cleanup says otherwise
+const DefaultCleanupTimeout = 5 * time.Second
+
+// StepTimeouts bound the individual steps of an upload
+// A zero duration leaves the step bounded by the caller's context alone
+type StepTimeouts struct {
+	Auth     time.Duration // ValidateToken and the Policy check
+	Metadata time.Duration // Each CreateFileRecord and UpdateFileStatus call
+	Storage  time.Duration // The content upload and the stored checksum check
+	Quota    time.Duration // Each Reserve and Commit call
+	Keys     time.Duration // Each GenerateDataKey and UnwrapDataKey call
+
+	// Cleanup is both the timeout of each compensation (marking the record
+	// failed, releasing quota, deleting an orphaned object) and the part of the
+	// caller's deadline held back from the other steps so those compensations
+	// can finish before the caller gives up
+	Cleanup time.Duration
+}
+
+// WithStepTimeouts gives each step of UploadFile and UploadFiles its own deadline
+// A slow step then fails with a timeout instead of using up the time the
+// remaining steps, and the cleanup after a failure, would need
+func WithStepTimeouts(timeouts StepTimeouts) Option {
+	return func(g *CloudStorageGateway) {
+		g.timeouts = timeouts
+	}
+}
+
+// stepContext derives the context for a step bounded by timeout
+// When ctx has a deadline, the configured cleanup reserve is taken off it first
+func (g *CloudStorageGateway) stepContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if ok {
+		deadline = deadline.Add(-g.timeouts.Cleanup)
+	}
+	if timeout > 0 {
+		if d := time.Now().Add(timeout); !ok || d.Before(deadline) {
+			deadline, ok = d, true
+		}
+	}
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
+// cleanupContext derives the context for a compensation
+// It keeps ctx's values but not its cancellation, so compensations still run
+// after the caller's deadline has passed, bounded by their own timeout
+func (g *CloudStorageGateway) cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := g.timeouts.Cleanup
+	if timeout <= 0 {
+		timeout = DefaultCleanupTimeout
+	}
+	return context.WithTimeout(context.WithoutCancel(ctx), timeout)
+}
+
+// step runs fn with a context bounded by timeout, as derived by stepContext
+func (g *CloudStorageGateway) step(ctx context.Context, timeout time.Duration, fn func(context.Context) error) error {
+	ctx, cancel := g.stepContext(ctx, timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// cleanup runs the compensation fn with a context derived by cleanupContext
+func (g *CloudStorageGateway) cleanup(ctx context.Context, fn func(context.Context) error) error {
+	ctx, cancel := g.cleanupContext(ctx)
+	defer cancel()
+	return fn(ctx)
+}
+
+// markFailed moves the record to StatusFailed within the cleanup budget
+// Failures are ignored: the upload has already failed with a more relevant
+// error, and the Reconciler picks up records left behind
+func (g *CloudStorageGateway) markFailed(ctx context.Context, ev Event) {
+	_ = g.cleanup(ctx, func(ctx context.Context) error {
+		return g.finishUpload(ctx, StatusFailed, ev)
+	})
+}
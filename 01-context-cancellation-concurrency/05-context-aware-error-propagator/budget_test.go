@@ -0,0 +1,110 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stallMethod blocks calls to method until their context is done and makes
+// every other call fail if its context is already done, like a real backend
+func stallMethod(method string) Interceptor {
+	return func(ctx context.Context, call Call, invoke Invoker) error {
+		if call.Method == method {
+			<-ctx.Done()
+			return NewStorageError(call.Method, call.Bucket, call.Key, ctx.Err(), AsTimeout(), AsTemporary())
+		}
+		if err := ctx.Err(); err != nil {
+			return NewMetadataError(call.Method, call.FileID, err)
+		}
+		return invoke(ctx)
+	}
+}
+
+// ============================================================================
+// Step Budget Tests
+// ============================================================================
+
+func TestCloudStorageGateway_StepTimeoutBoundsSlowAuth(t *testing.T) {
+	gateway := NewCloudStorageGateway(&mockAuthService{userID: "user123"}, &mockMetadataService{fileID: "file456"}, &mockStorageService{},
+		WithInterceptors(stallMethod("ValidateToken")),
+		WithStepTimeouts(StepTimeouts{Auth: 20 * time.Millisecond}),
+	)
+
+	start := time.Now()
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{Token: "valid-token", FileName: "test.txt", Bucket: "my-bucket", Data: []byte("hello")})
+
+	if !IsTimeout(err) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the auth step to time out, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the auth step should be cut off at its own timeout, took %v", elapsed)
+	}
+}
+
+func TestCloudStorageGateway_CleanupRunsAfterCallerDeadline(t *testing.T) {
+	store := newMemFileStore()
+	quota := NewInMemoryQuotaService(map[string]int64{"my-bucket": 100})
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store,
+		WithInterceptors(stallMethod("UploadFile")),
+		WithQuotaService(quota),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := gateway.UploadFile(ctx, FileUploadRequest{Token: "alice", FileName: "test.txt", Bucket: "my-bucket", Data: []byte("hello")})
+
+	if !IsTimeout(err) {
+		t.Fatalf("expected the storage timeout, got: %v", err)
+	}
+	if len(store.records) != 1 {
+		t.Fatalf("expected one record, got %d", len(store.records))
+	}
+	for id, rec := range store.records {
+		if rec.Status != StatusFailed {
+			t.Errorf("%s: status = %q, want failed even though the caller's deadline passed", id, rec.Status)
+		}
+	}
+	if usage := quota.Usage("my-bucket"); usage != 0 {
+		t.Errorf("the reservation should be released, usage = %d", usage)
+	}
+}
+
+func TestCloudStorageGateway_CleanupReserveShortensSteps(t *testing.T) {
+	gateway := NewCloudStorageGateway(&mockAuthService{}, &mockMetadataService{}, &mockStorageService{},
+		WithStepTimeouts(StepTimeouts{Storage: time.Hour, Cleanup: 10 * time.Minute}),
+	)
+	callerDeadline := time.Now().Add(30 * time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), callerDeadline)
+	defer cancel()
+
+	stepCtx, stepCancel := gateway.stepContext(ctx, gateway.timeouts.Storage)
+	defer stepCancel()
+
+	deadline, ok := stepCtx.Deadline()
+	if want := callerDeadline.Add(-10 * time.Minute); !ok || !deadline.Equal(want) {
+		t.Errorf("step deadline = %v, want the caller's deadline minus the cleanup reserve (%v)", deadline, want)
+	}
+
+	cleanupCtx, cleanupCancel := gateway.cleanupContext(ctx)
+	defer cleanupCancel()
+	cancel()
+	if cleanupCtx.Err() != nil {
+		t.Error("cancelling the caller must not cancel cleanup")
+	}
+	if deadline, ok := cleanupCtx.Deadline(); !ok || time.Until(deadline) > 10*time.Minute {
+		t.Errorf("cleanup should be bounded by its own timeout, deadline %v", deadline)
+	}
+}
+
+func TestCloudStorageGateway_NoStepTimeouts(t *testing.T) {
+	gateway := NewCloudStorageGateway(&mockAuthService{}, &mockMetadataService{}, &mockStorageService{})
+
+	stepCtx, cancel := gateway.stepContext(context.Background(), gateway.timeouts.Metadata)
+	defer cancel()
+
+	if _, ok := stepCtx.Deadline(); ok {
+		t.Error("without timeouts or a caller deadline a step must not get a deadline")
+	}
+}
@@ -0,0 +1,122 @@
+package propagator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+// ============================================================================
+// Checksums
+// ============================================================================
+
+// ChecksumAlgorithm identifies the hash used to verify upload content
+type ChecksumAlgorithm string
+
+const (
+	ChecksumSHA256 ChecksumAlgorithm = "sha256"
+	ChecksumCRC32C ChecksumAlgorithm = "crc32c"
+)
+
+// crc32cTable is the Castagnoli table used for CRC32C checksums
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Checksum is a hex-encoded digest together with the algorithm that produced it
+type Checksum struct {
+	Algorithm ChecksumAlgorithm
+	Value     string
+}
+
+// String renders the checksum as "algorithm:value"
+func (c Checksum) String() string {
+	return fmt.Sprintf("%s:%s", c.Algorithm, c.Value)
+}
+
+// Equal reports whether both checksums use the same algorithm and digest
+// Digests are compared case-insensitively since hex encodings vary between clients
+func (c Checksum) Equal(other Checksum) bool {
+	return c.Algorithm == other.Algorithm && strings.EqualFold(c.Value, other.Value)
+}
+
+// newHash returns a fresh hash.Hash for the algorithm
+func (a ChecksumAlgorithm) newHash() (hash.Hash, error) {
+	switch a {
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	case ChecksumCRC32C:
+		return crc32.New(crc32cTable), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedChecksum, string(a))
+	}
+}
+
+// ComputeChecksum hashes data with the given algorithm
+func ComputeChecksum(alg ChecksumAlgorithm, data []byte) (Checksum, error) {
+	h, err := alg.newHash()
+	if err != nil {
+		return Checksum{}, err
+	}
+	h.Write(data)
+	return Checksum{Algorithm: alg, Value: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// ComputeChecksumReader hashes everything read from r with the given algorithm
+// It lets storage backends checksum objects without loading them into memory
+func ComputeChecksumReader(alg ChecksumAlgorithm, r io.Reader) (Checksum, error) {
+	h, err := alg.newHash()
+	if err != nil {
+		return Checksum{}, err
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return Checksum{}, err
+	}
+	return Checksum{Algorithm: alg, Value: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// verifyChecksum computes the checksum of data and compares it to expected
+func verifyChecksum(op, bucket, key string, expected Checksum, data []byte) error {
+	actual, err := ComputeChecksum(expected.Algorithm, data)
+	if err != nil {
+		return err
+	}
+	if !actual.Equal(expected) {
+		return NewIntegrityError(op, bucket, key, expected, actual)
+	}
+	return nil
+}
+
+// IntegrityError reports that content did not match its expected checksum
+// It is never temporary: retrying the same bytes yields the same mismatch
+type IntegrityError struct {
+	Op       string // Stage that detected the mismatch (e.g., "verify_request", "verify_stored")
+	Bucket   string
+	Key      string
+	Expected Checksum
+	Actual   Checksum
+	Err      error
+	stack    stack
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Errorf("integrity error during %s for bucket %s and key %s: expected %s, got %s: %w",
+		e.Op, e.Bucket, e.Key, e.Expected, e.Actual, e.Err).Error()
+}
+
+func (e *IntegrityError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary always returns false so retry loops give up on corrupted content
+func (e *IntegrityError) Temporary() bool {
+	return false
+}
+
+// Sentinel errors for checksum verification
+var (
+	ErrChecksumMismatch    = NewSentinel(CodeChecksumMismatch, "checksum mismatch")
+	ErrUnsupportedChecksum = NewSentinel(CodeUnsupportedChecksum, "unsupported checksum algorithm")
+)
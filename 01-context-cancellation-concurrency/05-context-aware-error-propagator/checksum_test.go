@@ -0,0 +1,154 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// corruptingStorageService stores a different payload than the one it receives
+type corruptingStorageService struct {
+	mockStorageService
+}
+
+func (m *corruptingStorageService) UploadFile(ctx context.Context, bucket, key string, data []byte) error {
+	m.stored = append([]byte("corrupted:"), data...)
+	return nil
+}
+
+// ============================================================================
+// Checksum Tests
+// ============================================================================
+
+func TestComputeChecksum(t *testing.T) {
+	tests := []struct {
+		name string
+		alg  ChecksumAlgorithm
+		want string
+	}{
+		{
+			name: "sha256",
+			alg:  ChecksumSHA256,
+			want: "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+		},
+		{
+			name: "crc32c",
+			alg:  ChecksumCRC32C,
+			want: "c99465aa",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ComputeChecksum(tt.alg, []byte("hello world"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Value != tt.want {
+				t.Errorf("ComputeChecksum() = %s, want %s", got.Value, tt.want)
+			}
+		})
+	}
+
+	if _, err := ComputeChecksum("md5", nil); !errors.Is(err, ErrUnsupportedChecksum) {
+		t.Errorf("expected ErrUnsupportedChecksum, got: %v", err)
+	}
+}
+
+func TestIntegrityError_NotTemporary(t *testing.T) {
+	err := WrapWithContext(&IntegrityError{Op: "verify_stored", Err: ErrChecksumMismatch}, "gateway")
+
+	if IsTemporary(err) {
+		t.Error("IntegrityError must never be classified as temporary")
+	}
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Error("errors.Is should find ErrChecksumMismatch through IntegrityError")
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_ChecksumVerified(t *testing.T) {
+	data := []byte("hello world")
+	sum, _ := ComputeChecksum(ChecksumSHA256, data)
+
+	gateway := NewCloudStorageGateway(
+		&mockAuthService{userID: "user123"},
+		&mockMetadataService{fileID: "file456"},
+		&mockStorageService{},
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     data,
+		Checksum: &sum,
+	})
+
+	if err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_RequestChecksumMismatch(t *testing.T) {
+	wrong, _ := ComputeChecksum(ChecksumCRC32C, []byte("something else"))
+	storage := &mockStorageService{}
+
+	gateway := NewCloudStorageGateway(
+		&mockAuthService{userID: "user123"},
+		&mockMetadataService{createErr: errors.New("metadata must not be written")},
+		storage,
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     []byte("hello world"),
+		Checksum: &wrong,
+	})
+
+	var integrityErr *IntegrityError
+	if !errors.As(err, &integrityErr) {
+		t.Fatalf("expected IntegrityError, got: %v", err)
+	}
+	if integrityErr.Op != "verify_request" {
+		t.Errorf("expected Op='verify_request', got '%s'", integrityErr.Op)
+	}
+	if storage.stored != nil {
+		t.Error("storage must not be called when the request checksum does not match")
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_StoredChecksumMismatch(t *testing.T) {
+	data := []byte("hello world")
+	sum, _ := ComputeChecksum(ChecksumSHA256, data)
+	storage := &corruptingStorageService{}
+
+	gateway := NewCloudStorageGateway(
+		&mockAuthService{userID: "user123"},
+		&mockMetadataService{fileID: "file456"},
+		storage,
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     data,
+		Checksum: &sum,
+	})
+
+	var integrityErr *IntegrityError
+	if !errors.As(err, &integrityErr) {
+		t.Fatalf("expected IntegrityError, got: %v", err)
+	}
+	if integrityErr.Op != "verify_stored" || integrityErr.Key != "file456" {
+		t.Errorf("unexpected IntegrityError details: %+v", integrityErr)
+	}
+	if IsTemporary(err) {
+		t.Error("checksum mismatch must not be temporary")
+	}
+	if len(storage.deleted) != 1 || storage.deleted[0] != "file456" {
+		t.Errorf("the corrupt object should be deleted, got %v", storage.deleted)
+	}
+}
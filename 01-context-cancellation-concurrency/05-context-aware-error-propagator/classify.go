@@ -0,0 +1,101 @@
+package propagator
+
+import (
+	"context"
+	"os"
+)
+
+// ============================================================================
+// Error Classification
+// ============================================================================
+
+// ClassifyPolicy decides how the branches of a joined error are combined
+type ClassifyPolicy int
+
+const (
+	// AnyBranch matches when at least one branch matches
+	AnyBranch ClassifyPolicy = iota
+	// AllBranches matches only when every branch matches
+	AllBranches
+)
+
+// Classification is the outcome of ClassifyTimeout or ClassifyTemporary
+type Classification struct {
+	Matched bool
+	// Cause is the error that drove the decision: the one whose Timeout() or
+	// Temporary() answered, or, when nothing answered, the leaf of the chain
+	// For AllBranches misses it is the branch that failed to match
+	Cause error
+}
+
+// ClassifyTimeout reports whether err is a timeout under the given policy
+// Within a single chain any error reporting Timeout() == true decides, so a
+// timeout stays visible however it was wrapped. This covers the package's
+// error types as well as context.DeadlineExceeded, os.ErrDeadlineExceeded and
+// net.Error values, which all implement Timeout()
+func ClassifyTimeout(err error, policy ClassifyPolicy) Classification {
+	return classify(err, policy, func(e error) (matched, decided bool) {
+		if e == context.DeadlineExceeded || e == os.ErrDeadlineExceeded {
+			return true, true
+		}
+		if te, ok := e.(timeoutError); ok && te.Timeout() {
+			return true, true
+		}
+		return false, false
+	})
+}
+
+// ClassifyTemporary reports whether err is temporary under the given policy
+// Within a single chain the outermost error implementing Temporary() decides,
+// so a layer can deliberately mark a retriable cause as permanent
+func ClassifyTemporary(err error, policy ClassifyPolicy) Classification {
+	return classify(err, policy, func(e error) (matched, decided bool) {
+		if te, ok := e.(temporaryError); ok {
+			return te.Temporary(), true
+		}
+		return false, false
+	})
+}
+
+// classify walks err's chain until decide answers, splitting at joined errors
+func classify(err error, policy ClassifyPolicy, decide func(error) (matched, decided bool)) Classification {
+	var last error
+	for err != nil {
+		if matched, decided := decide(err); decided {
+			return Classification{Matched: matched, Cause: err}
+		}
+		last = err
+
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			err = u.Unwrap()
+		case interface{ Unwrap() []error }:
+			return classifyBranches(u.Unwrap(), policy, decide)
+		default:
+			err = nil
+		}
+	}
+	return Classification{Cause: last}
+}
+
+// classifyBranches combines the classification of each branch of a joined error
+func classifyBranches(branches []error, policy ClassifyPolicy, decide func(error) (matched, decided bool)) Classification {
+	var first Classification
+	for i, branch := range branches {
+		c := classify(branch, policy, decide)
+		if i == 0 {
+			first = c
+		}
+		switch {
+		case policy == AnyBranch && c.Matched:
+			return c
+		case policy == AllBranches && !c.Matched:
+			return c
+		}
+	}
+	if len(branches) == 0 {
+		return Classification{}
+	}
+	// AnyBranch: nothing matched; AllBranches: everything matched
+	return first
+}
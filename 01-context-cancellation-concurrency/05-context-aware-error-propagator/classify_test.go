@@ -0,0 +1,102 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+)
+
+// ============================================================================
+// Classification Tests
+// ============================================================================
+
+func TestIsTimeout_StandardLibraryTimeouts(t *testing.T) {
+	opErr := &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "os.ErrDeadlineExceeded", err: fmt.Errorf("read: %w", os.ErrDeadlineExceeded)},
+		{name: "net.Error timeout", err: WrapWithContext(opErr, "storage call")},
+		{name: "StorageError wrapping net.Error", err: &StorageError{Op: "upload", Err: opErr}},
+		{name: "context.DeadlineExceeded under non-timeout AuthError", err: &AuthError{Op: "validate_token", Err: context.DeadlineExceeded}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !IsTimeout(tt.err) {
+				t.Errorf("IsTimeout(%v) = false, want true", tt.err)
+			}
+		})
+	}
+}
+
+func TestIsTemporary_JoinedErrorsAnyBranch(t *testing.T) {
+	// errors.As stops at the first AuthError and would report false here
+	err := errors.Join(
+		&AuthError{Op: "validate_token", Err: ErrInvalidToken},
+		&StorageError{Op: "upload", Err: ErrStorageUnavailable, isTemp: true},
+	)
+
+	if !IsTemporary(err) {
+		t.Error("IsTemporary should consider every branch of a joined error")
+	}
+}
+
+func TestClassifyTemporary_Policies(t *testing.T) {
+	permanent := &AuthError{Op: "validate_token", Err: ErrInvalidToken}
+	temporary := &StorageError{Op: "upload", Err: ErrStorageUnavailable, isTemp: true}
+	mixed := WrapWithContext(errors.Join(temporary, permanent), "batch failed")
+
+	anyC := ClassifyTemporary(mixed, AnyBranch)
+	if !anyC.Matched || anyC.Cause != temporary {
+		t.Errorf("AnyBranch: got %+v, want match caused by the StorageError", anyC)
+	}
+
+	allC := ClassifyTemporary(mixed, AllBranches)
+	if allC.Matched || allC.Cause != permanent {
+		t.Errorf("AllBranches: got %+v, want miss caused by the AuthError", allC)
+	}
+
+	bothTemp := errors.Join(temporary, &MetadataError{Op: "update", Err: ErrDatabaseDeadlock, isTemp: true})
+	if !ClassifyTemporary(bothTemp, AllBranches).Matched {
+		t.Error("AllBranches should match when every branch is temporary")
+	}
+}
+
+func TestClassifyTemporary_OuterLayerDecides(t *testing.T) {
+	// A layer that marks a cause permanent overrides the deadline's Temporary() == true
+	err := &StorageError{Op: "upload", Err: context.DeadlineExceeded}
+
+	c := ClassifyTemporary(err, AnyBranch)
+	if c.Matched || c.Cause != err {
+		t.Errorf("got %+v, want the StorageError to decide non-temporary", c)
+	}
+	if !ClassifyTemporary(context.DeadlineExceeded, AnyBranch).Matched {
+		t.Error("a bare context.DeadlineExceeded reports itself temporary")
+	}
+}
+
+func TestClassifyTimeout_ReportsLeaf(t *testing.T) {
+	leaf := &StorageError{Op: "upload", Err: errors.New("slow"), isTimeout: true}
+	err := WrapWithContext(fmt.Errorf("storage: %w", leaf), "upload failed")
+
+	c := ClassifyTimeout(err, AnyBranch)
+	if !c.Matched || c.Cause != leaf {
+		t.Errorf("got %+v, want the StorageError as cause", c)
+	}
+
+	plain := errors.New("regular error")
+	miss := ClassifyTimeout(WrapWithContext(plain, "wrap"), AnyBranch)
+	if miss.Matched || miss.Cause != plain {
+		t.Errorf("unmatched chains should report their leaf, got %+v", miss)
+	}
+
+	if c := ClassifyTimeout(nil, AllBranches); c.Matched || c.Cause != nil {
+		t.Errorf("nil error should not match, got %+v", c)
+	}
+}
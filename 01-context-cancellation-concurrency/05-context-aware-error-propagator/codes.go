@@ -0,0 +1,191 @@
+package propagator
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// ============================================================================
+// Error Codes
+// ============================================================================
+
+// ErrorCode is a stable, machine-readable identifier for a failure
+// Codes never change once published, unlike error messages
+type ErrorCode string
+
+// CodeUnknown is returned by Code for errors that carry no code
+const CodeUnknown ErrorCode = "UNKNOWN"
+
+// Codes for the error types and sentinels defined by this package
+const (
+	CodeAuthError               ErrorCode = "AUTH_ERROR"
+	CodeAuthFailed              ErrorCode = "AUTH_FAILED"
+	CodeAuthTokenExpired        ErrorCode = "AUTH_TOKEN_EXPIRED"
+	CodeAuthInvalidToken        ErrorCode = "AUTH_INVALID_TOKEN"
+	CodeMetadataError           ErrorCode = "METADATA_ERROR"
+	CodeMetadataDeadlock        ErrorCode = "METADATA_DATABASE_DEADLOCK"
+	CodeStorageError            ErrorCode = "STORAGE_ERROR"
+	CodeStorageUnavailable      ErrorCode = "STORAGE_UNAVAILABLE"
+	CodeStorageQuotaExceeded    ErrorCode = "STORAGE_QUOTA_EXCEEDED"
+	CodeQuotaExceeded           ErrorCode = "QUOTA_EXCEEDED"
+	CodeIntegrityError          ErrorCode = "INTEGRITY_ERROR"
+	CodeChecksumMismatch        ErrorCode = "INTEGRITY_CHECKSUM_MISMATCH"
+	CodeUnsupportedChecksum     ErrorCode = "INTEGRITY_UNSUPPORTED_CHECKSUM"
+	CodeUnknownReservation      ErrorCode = "QUOTA_UNKNOWN_RESERVATION"
+	CodeBatchAborted            ErrorCode = "BATCH_ABORTED"
+	CodeUploadAbandoned         ErrorCode = "RECONCILE_UPLOAD_ABANDONED"
+	CodeFileNotFound            ErrorCode = "METADATA_FILE_NOT_FOUND"
+	CodeObjectNotFound          ErrorCode = "STORAGE_OBJECT_NOT_FOUND"
+	CodeObjectExists            ErrorCode = "STORAGE_OBJECT_EXISTS"
+	CodeKeyInUse                ErrorCode = "METADATA_KEY_IN_USE"
+	CodeInvalidRange            ErrorCode = "STORAGE_INVALID_RANGE"
+	CodeInvalidObjectName       ErrorCode = "STORAGE_INVALID_OBJECT_NAME"
+	CodeInvalidStatusTransition ErrorCode = "METADATA_INVALID_STATUS_TRANSITION"
+	CodeStatusTransitionError   ErrorCode = "STATUS_TRANSITION_ERROR"
+	CodeValidationError         ErrorCode = "VALIDATION_ERROR"
+	CodeValidationFailed        ErrorCode = "VALIDATION_FAILED"
+	CodePermissionError         ErrorCode = "PERMISSION_ERROR"
+	CodePermissionDenied        ErrorCode = "PERMISSION_DENIED"
+	CodeUnknownRole             ErrorCode = "POLICY_UNKNOWN_ROLE"
+	CodeKeyProviderError        ErrorCode = "KEY_PROVIDER_ERROR"
+	CodeKeyNotFound             ErrorCode = "KEY_PROVIDER_KEY_NOT_FOUND"
+	CodeNoKeyProvider           ErrorCode = "KEY_PROVIDER_NOT_CONFIGURED"
+	CodeDecryptionFailed        ErrorCode = "ENCRYPTION_DECRYPTION_FAILED"
+)
+
+// Coder is implemented by errors that carry a stable code
+type Coder interface {
+	Code() ErrorCode
+}
+
+// Code returns the most specific code in err's tree
+// The deepest coded error wins, so an AuthError wrapping ErrTokenExpired
+// reports AUTH_TOKEN_EXPIRED rather than AUTH_ERROR
+// Returns "" for a nil error and CodeUnknown when nothing in the tree has a code
+func Code(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	code, _ := deepestCode(err, 0)
+	if code == "" {
+		return CodeUnknown
+	}
+	return code
+}
+
+// deepestCode returns the code of the deepest Coder below err and its depth
+// On ties the first branch of a joined error wins
+func deepestCode(err error, depth int) (ErrorCode, int) {
+	if err == nil {
+		return "", -1
+	}
+	best, bestDepth := ErrorCode(""), -1
+	if c, ok := err.(Coder); ok {
+		best, bestDepth = c.Code(), depth
+	}
+	var children []error
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		children = []error{u.Unwrap()}
+	case interface{ Unwrap() []error }:
+		children = u.Unwrap()
+	}
+	for _, child := range children {
+		if code, d := deepestCode(child, depth+1); d > bestDepth {
+			best, bestDepth = code, d
+		}
+	}
+	return best, bestDepth
+}
+
+func (e *AuthError) Code() ErrorCode             { return CodeAuthError }
+func (e *MetadataError) Code() ErrorCode         { return CodeMetadataError }
+func (e *StorageError) Code() ErrorCode          { return CodeStorageError }
+func (e *StorageQuotaError) Code() ErrorCode     { return CodeStorageQuotaExceeded }
+func (e *IntegrityError) Code() ErrorCode        { return CodeIntegrityError }
+func (e *PermissionError) Code() ErrorCode       { return CodePermissionError }
+func (e *StatusTransitionError) Code() ErrorCode { return CodeStatusTransitionError }
+func (e *ValidationError) Code() ErrorCode       { return CodeValidationError }
+func (e *KeyProviderError) Code() ErrorCode      { return CodeKeyProviderError }
+
+// ============================================================================
+// Code Registry
+// ============================================================================
+
+// Registry errors
+var (
+	ErrCodeConflict = errors.New("error code already registered")
+	ErrInvalidCode  = errors.New("invalid error code")
+)
+
+// validCode restricts codes to SCREAMING_SNAKE_CASE
+var validCode = regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`)
+
+var registry = struct {
+	sync.RWMutex
+	codes map[ErrorCode]string
+}{codes: make(map[ErrorCode]string)}
+
+func init() {
+	MustRegisterCode(CodeUnknown, "error without a registered code")
+	MustRegisterCode(CodeAuthError, "authentication service failure")
+	MustRegisterCode(CodeMetadataError, "metadata service failure")
+	MustRegisterCode(CodeStorageError, "storage service failure")
+	MustRegisterCode(CodeStorageQuotaExceeded, "storage quota exceeded")
+	MustRegisterCode(CodeIntegrityError, "content integrity check failed")
+	MustRegisterCode(CodePermissionError, "authorization policy failure")
+	MustRegisterCode(CodeStatusTransitionError, "file status change refused")
+	MustRegisterCode(CodeValidationError, "upload request rejected by validation rules")
+	MustRegisterCode(CodeKeyProviderError, "encryption key provider failure")
+}
+
+// RegisterCode reserves code for the caller so other services cannot reuse it
+// Returns ErrCodeConflict if the code is taken and ErrInvalidCode if it is not SCREAMING_SNAKE_CASE
+func RegisterCode(code ErrorCode, description string) error {
+	if !validCode.MatchString(string(code)) {
+		return fmt.Errorf("%w: %q", ErrInvalidCode, string(code))
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if existing, ok := registry.codes[code]; ok {
+		return fmt.Errorf("%w: %s (%s)", ErrCodeConflict, code, existing)
+	}
+	registry.codes[code] = description
+	return nil
+}
+
+// MustRegisterCode is like RegisterCode but panics on failure
+// Intended for package initialization, where a conflict is a programming error
+func MustRegisterCode(code ErrorCode, description string) {
+	if err := RegisterCode(code, description); err != nil {
+		panic(err)
+	}
+}
+
+// LookupCode returns the description a code was registered with
+func LookupCode(code ErrorCode) (description string, ok bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	description, ok = registry.codes[code]
+	return description, ok
+}
+
+// codedError is a sentinel error that carries a code
+type codedError struct {
+	code ErrorCode
+	msg  string
+}
+
+func (e *codedError) Error() string   { return e.msg }
+func (e *codedError) Code() ErrorCode { return e.code }
+
+// NewSentinel registers code and returns a sentinel error carrying it
+// It panics if the code is already registered, so use it for package-level vars
+func NewSentinel(code ErrorCode, msg string) error {
+	MustRegisterCode(code, msg)
+	return &codedError{code: code, msg: msg}
+}
@@ -0,0 +1,123 @@
+package propagator
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+// testCodeSeq keeps registered test codes unique across -count runs
+var testCodeSeq atomic.Int64
+
+func uniqueTestCode(prefix string) ErrorCode {
+	return ErrorCode(fmt.Sprintf("%s_%d", prefix, testCodeSeq.Add(1)))
+}
+
+// ============================================================================
+// Error Code Tests
+// ============================================================================
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{
+			name: "nil error",
+			err:  nil,
+			want: "",
+		},
+		{
+			name: "uncoded error",
+			err:  errors.New("boom"),
+			want: CodeUnknown,
+		},
+		{
+			name: "bare sentinel",
+			err:  ErrTokenExpired,
+			want: CodeAuthTokenExpired,
+		},
+		{
+			name: "typed error wrapping sentinel reports the sentinel",
+			err:  WrapWithContext(&AuthError{Op: "validate_token", Err: ErrTokenExpired}, "upload failed: auth"),
+			want: CodeAuthTokenExpired,
+		},
+		{
+			name: "typed error wrapping uncoded cause reports the type",
+			err:  WrapWithContext(&StorageError{Op: "upload", Err: errors.New("disk on fire")}, "upload failed: storage"),
+			want: CodeStorageError,
+		},
+		{
+			name: "quota error",
+			err:  &StorageQuotaError{Bucket: "b", Err: errors.New("over")},
+			want: CodeStorageQuotaExceeded,
+		},
+		{
+			name: "deepest code in a joined error wins",
+			err: errors.Join(
+				&StorageError{Op: "upload", Err: errors.New("x")},
+				&MetadataError{Op: "update", Err: fmt.Errorf("retry: %w", ErrDatabaseDeadlock)},
+			),
+			want: CodeMetadataDeadlock,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Code(tt.err); got != tt.want {
+				t.Errorf("Code() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSentinels_RemainComparable(t *testing.T) {
+	err := WrapWithContext(&MetadataError{Op: "insert", Err: ErrDatabaseDeadlock}, "create file record failed")
+
+	if !errors.Is(err, ErrDatabaseDeadlock) {
+		t.Error("errors.Is should still match coded sentinels")
+	}
+	if ErrDatabaseDeadlock.Error() != "database deadlock" {
+		t.Errorf("sentinel message changed: %q", ErrDatabaseDeadlock.Error())
+	}
+}
+
+func TestRegisterCode(t *testing.T) {
+	code := uniqueTestCode("TEST_BILLING_CARD_DECLINED")
+
+	if err := RegisterCode(code, "card declined"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if desc, ok := LookupCode(code); !ok || desc != "card declined" {
+		t.Errorf("LookupCode() = %q, %v", desc, ok)
+	}
+
+	if err := RegisterCode(code, "again"); !errors.Is(err, ErrCodeConflict) {
+		t.Errorf("expected ErrCodeConflict, got: %v", err)
+	}
+	if err := RegisterCode(CodeAuthTokenExpired, "collides with built-in"); !errors.Is(err, ErrCodeConflict) {
+		t.Errorf("built-in codes must be reserved, got: %v", err)
+	}
+	if err := RegisterCode("not-a-code", "lowercase"); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("expected ErrInvalidCode, got: %v", err)
+	}
+}
+
+func TestNewSentinel(t *testing.T) {
+	code := uniqueTestCode("TEST_PAYMENT_DECLINED")
+	errCardDeclined := NewSentinel(code, "payment declined")
+
+	err := fmt.Errorf("checkout: %w", errCardDeclined)
+	if got := Code(err); got != code {
+		t.Errorf("Code() = %q, want %q", got, code)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NewSentinel should panic on a duplicate code")
+		}
+	}()
+	NewSentinel(code, "duplicate")
+}
@@ -0,0 +1,381 @@
+package propagator
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ============================================================================
+// Encryption at Rest
+// ============================================================================
+
+// Envelope encryption parameters
+// Each file is sealed with its own random AES-256 data key in chunks of
+// EncryptionChunkSize plaintext bytes. Chunk i is sealed with AES-GCM under
+// a nonce holding i and a flag marking the final chunk, so chunks cannot be
+// reordered, dropped or truncated without failing authentication
+const (
+	DataKeySize         = 32
+	EncryptionChunkSize = 64 << 10
+)
+
+// gcmTagSize is the authentication tag AES-GCM appends to every chunk
+const gcmTagSize = 16
+
+// Encryption describes how a file's content is encrypted at rest
+// It holds no secrets: the data key is only stored wrapped by the KeyProvider
+type Encryption struct {
+	KeyID      string // KeyProvider key that wrapped the data key
+	WrappedKey []byte // Data key encrypted by the KeyProvider
+	ChunkSize  int    // Plaintext bytes per sealed chunk
+}
+
+// DataKey is a data key generated by a KeyProvider
+type DataKey struct {
+	KeyID     string // KeyProvider key that wrapped it
+	Plaintext []byte // Used for a single file, then discarded; never stored
+	Wrapped   []byte
+}
+
+// KeyProvider wraps and unwraps per-file data keys under master keys it
+// never reveals, such as keys held by a KMS or an HSM
+type KeyProvider interface {
+	// GenerateDataKey returns a new DataKeySize-byte data key, both in plaintext
+	// and wrapped under the provider's current master key
+	// Returns KeyProviderError on failure
+	GenerateDataKey(ctx context.Context) (DataKey, error)
+
+	// UnwrapDataKey recovers a data key wrapped under the master key keyID
+	// Returns KeyProviderError wrapping ErrKeyNotFound if keyID is unknown
+	UnwrapDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// KeyProviderError represents key provider failures
+// It is distinct from StorageError so callers can tell an unavailable key
+// service apart from an unavailable blob store
+type KeyProviderError struct {
+	Op        string // Operation (e.g., "generate", "unwrap")
+	KeyID     string // Master key involved; empty when not known yet
+	Err       error  // Underlying error
+	isTimeout bool
+	isTemp    bool
+	stack     stack
+}
+
+func (e *KeyProviderError) Error() string {
+	return fmt.Errorf("key provider error during %s for key %q: %w", e.Op, e.KeyID, e.Err).Error()
+}
+
+func (e *KeyProviderError) Unwrap() error {
+	return e.Err
+}
+
+func (e *KeyProviderError) Timeout() bool {
+	return e.isTimeout
+}
+
+func (e *KeyProviderError) Temporary() bool {
+	return e.isTemp
+}
+
+// Sentinel errors for encrypted content
+var (
+	ErrKeyNotFound      = NewSentinel(CodeKeyNotFound, "encryption key not found")
+	ErrNoKeyProvider    = NewSentinel(CodeNoKeyProvider, "no key provider configured")
+	ErrDecryptionFailed = NewSentinel(CodeDecryptionFailed, "decryption failed")
+)
+
+// WithEncryption encrypts uploaded content before it reaches the StorageService
+// Every file gets its own data key, which is wrapped by keys and stored in
+// the file's record. Downloads of encrypted files are decrypted transparently,
+// so a gateway serving them needs the KeyProvider even after encryption is
+// turned off for new uploads
+func WithEncryption(keys KeyProvider) Option {
+	return func(g *CloudStorageGateway) {
+		g.keys = keys
+	}
+}
+
+// encryptContent seals data under a freshly generated data key
+// It returns the ciphertext and the Encryption to store with the file's record
+func (g *CloudStorageGateway) encryptContent(ctx context.Context, data []byte) ([]byte, *Encryption, error) {
+	var dk DataKey
+	err := g.step(ctx, g.timeouts.Keys, func(ctx context.Context) (err error) {
+		dk, err = g.keys.GenerateDataKey(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer clear(dk.Plaintext)
+
+	aead, err := newChunkAEAD(dk.Plaintext)
+	if err != nil {
+		return nil, nil, NewKeyProviderError("generate", dk.KeyID, err)
+	}
+	enc := &Encryption{KeyID: dk.KeyID, WrappedKey: dk.Wrapped, ChunkSize: EncryptionChunkSize}
+	return sealChunks(aead, data, enc.ChunkSize), enc, nil
+}
+
+// decryptDownload streams the plaintext of rng (nil for everything) of an encrypted file
+// Only the chunks overlapping the range are fetched from storage
+func (g *CloudStorageGateway) decryptDownload(ctx context.Context, rec FileRecord, rng *ByteRange) (io.ReadCloser, error) {
+	enc := rec.Encryption
+	if g.keys == nil {
+		return nil, NewKeyProviderError("unwrap", enc.KeyID, ErrNoKeyProvider)
+	}
+	if enc.ChunkSize <= 0 {
+		return nil, NewStorageError("decrypt", rec.Bucket, rec.ObjectKey(), ErrDecryptionFailed)
+	}
+
+	start, end := int64(0), rec.Size
+	if rng != nil {
+		var err error
+		if start, end, err = rng.Bounds(rec.Size); err != nil {
+			return nil, NewStorageError("download", rec.Bucket, rec.ObjectKey(), err)
+		}
+	}
+	if start == end {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	var key []byte
+	err := g.step(ctx, g.timeouts.Keys, func(ctx context.Context) (err error) {
+		key, err = g.keys.UnwrapDataKey(ctx, enc.KeyID, enc.WrappedKey)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newChunkAEAD(key)
+	clear(key)
+	if err != nil {
+		return nil, NewKeyProviderError("unwrap", enc.KeyID, err)
+	}
+
+	// Fetch whole chunks from the one holding start to the one holding end-1
+	chunkSize := int64(enc.ChunkSize)
+	sealedChunk := chunkSize + gcmTagSize
+	first, last := start/chunkSize, (end-1)/chunkSize
+	final := chunkCount(rec.Size, enc.ChunkSize) - 1
+	var sealedRange *ByteRange
+	if first > 0 || last < final {
+		sealedRange = &ByteRange{Offset: first * sealedChunk, Length: (last - first + 1) * sealedChunk}
+	}
+	body, err := g.storage.DownloadFile(ctx, rec.Bucket, rec.ObjectKey(), sealedRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		body:      body,
+		aead:      aead,
+		bucket:    rec.Bucket,
+		key:       rec.ObjectKey(),
+		size:      rec.Size,
+		chunkSize: chunkSize,
+		next:      first,
+		final:     final,
+		skip:      start - first*chunkSize,
+		remaining: end - start,
+	}, nil
+}
+
+// ============================================================================
+// Chunked AES-GCM
+// ============================================================================
+
+// newChunkAEAD returns AES-GCM keyed with a DataKeySize-byte data key
+func newChunkAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != DataKeySize {
+		return nil, fmt.Errorf("data key is %d bytes, want %d", len(key), DataKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkCount returns how many chunks a plaintext of size bytes is sealed in
+// Empty content is still sealed as one (empty) final chunk so it can be authenticated
+func chunkCount(size int64, chunkSize int) int64 {
+	return max(1, (size+int64(chunkSize)-1)/int64(chunkSize))
+}
+
+// sealedSize returns how many bytes a plaintext of size bytes takes once sealed
+func sealedSize(size int64) int64 {
+	return size + chunkCount(size, EncryptionChunkSize)*gcmTagSize
+}
+
+// chunkNonce is the nonce of chunk index; the data key is never reused across
+// files, so the chunk position is all the nonce needs to make unique
+func chunkNonce(index int64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], uint64(index))
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// sealChunks encrypts data chunk by chunk
+func sealChunks(aead cipher.AEAD, data []byte, chunkSize int) []byte {
+	n := chunkCount(int64(len(data)), chunkSize)
+	out := make([]byte, 0, int64(len(data))+n*gcmTagSize)
+	for i := range n {
+		chunk := data[min(int(i)*chunkSize, len(data)):min(int(i+1)*chunkSize, len(data))]
+		out = aead.Seal(out, chunkNonce(i, i == n-1), chunk, nil)
+	}
+	return out
+}
+
+// decryptReader opens sealed chunks as they are read from body
+// Anything that fails authentication, including a stream that ends early,
+// is reported as a StorageError wrapping ErrDecryptionFailed
+type decryptReader struct {
+	body      io.ReadCloser
+	aead      cipher.AEAD
+	bucket    string
+	key       string
+	size      int64 // Plaintext size of the whole file
+	chunkSize int64
+	next      int64 // Index of the next chunk in body
+	final     int64 // Index of the file's final chunk
+	skip      int64 // Plaintext to drop before the range starts
+	remaining int64 // Plaintext left to return
+	buf       []byte
+	plain     []byte // Opened plaintext not returned yet
+	err       error
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.remaining == 0 {
+			return 0, io.EOF
+		}
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.openNext()
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// openNext reads, authenticates and trims the next chunk
+func (r *decryptReader) openNext() error {
+	plainLen := r.chunkSize
+	if r.next == r.final {
+		plainLen = r.size - r.final*r.chunkSize
+	}
+	sealed := plainLen + gcmTagSize
+	if int64(cap(r.buf)) < sealed {
+		r.buf = make([]byte, sealed)
+	}
+	buf := r.buf[:sealed]
+	if _, err := io.ReadFull(r.body, buf); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return NewStorageError("decrypt", r.bucket, r.key, ErrDecryptionFailed)
+		}
+		return err
+	}
+	plain, err := r.aead.Open(buf[:0], chunkNonce(r.next, r.next == r.final), buf, nil)
+	if err != nil {
+		return NewStorageError("decrypt", r.bucket, r.key, ErrDecryptionFailed)
+	}
+	r.next++
+
+	plain = plain[r.skip:]
+	r.skip = 0
+	plain = plain[:min(int64(len(plain)), r.remaining)]
+	r.remaining -= int64(len(plain))
+	r.plain = plain
+	return nil
+}
+
+func (r *decryptReader) Close() error {
+	return r.body.Close()
+}
+
+// ============================================================================
+// Local Key Provider
+// ============================================================================
+
+// LocalKeyProvider is a KeyProvider holding AES master keys in memory
+// It suits tests and deployments that load master keys from a secret store.
+// New data keys are wrapped under the current key; every key can unwrap, so
+// master keys can be rotated without re-encrypting stored files
+type LocalKeyProvider struct {
+	keys    map[string]cipher.AEAD
+	current string
+}
+
+// NewLocalKeyProvider creates a provider wrapping new data keys under keys[current]
+// Master keys must be 16, 24 or 32 bytes long. Returns KeyProviderError
+// wrapping ErrKeyNotFound if current is not among keys
+func NewLocalKeyProvider(current string, keys map[string][]byte) (*LocalKeyProvider, error) {
+	p := &LocalKeyProvider{keys: make(map[string]cipher.AEAD, len(keys)), current: current}
+	for id, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, NewKeyProviderError("configure", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, NewKeyProviderError("configure", id, err)
+		}
+		p.keys[id] = aead
+	}
+	if _, ok := p.keys[current]; !ok {
+		return nil, NewKeyProviderError("configure", current, ErrKeyNotFound)
+	}
+	return p, nil
+}
+
+// GenerateDataKey returns a random data key wrapped under the current master key
+// The wrapped form is a random nonce followed by the sealed key, with the
+// master key ID as additional data
+func (p *LocalKeyProvider) GenerateDataKey(ctx context.Context) (DataKey, error) {
+	aead := p.keys[p.current]
+	key := make([]byte, DataKeySize)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(key); err != nil {
+		return DataKey{}, NewKeyProviderError("generate", p.current, err)
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return DataKey{}, NewKeyProviderError("generate", p.current, err)
+	}
+	return DataKey{
+		KeyID:     p.current,
+		Plaintext: key,
+		Wrapped:   aead.Seal(nonce, nonce, key, []byte(p.current)),
+	}, nil
+}
+
+// UnwrapDataKey opens a data key wrapped by GenerateDataKey
+// Returns KeyProviderError wrapping ErrKeyNotFound for unknown key IDs and
+// ErrDecryptionFailed if wrapped was not produced under keyID
+func (p *LocalKeyProvider) UnwrapDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return nil, NewKeyProviderError("unwrap", keyID, ErrKeyNotFound)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, NewKeyProviderError("unwrap", keyID, ErrDecryptionFailed)
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	key, err := aead.Open(nil, nonce, sealed, []byte(keyID))
+	if err != nil {
+		return nil, NewKeyProviderError("unwrap", keyID, ErrDecryptionFailed)
+	}
+	return key, nil
+}
@@ -0,0 +1,284 @@
+package propagator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// testKeyProvider returns a LocalKeyProvider wrapping under current
+func testKeyProvider(t *testing.T, current string, ids ...string) *LocalKeyProvider {
+	t.Helper()
+	keys := make(map[string][]byte)
+	for _, id := range ids {
+		keys[id] = bytes.Repeat([]byte(id[:1]), 32)
+	}
+	p, err := NewLocalKeyProvider(current, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// failingKeyProvider fails every call with err
+type failingKeyProvider struct {
+	err error
+}
+
+func (p failingKeyProvider) GenerateDataKey(ctx context.Context) (DataKey, error) {
+	return DataKey{}, p.err
+}
+
+func (p failingKeyProvider) UnwrapDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	return nil, p.err
+}
+
+// downloadAll reads a whole download, or the given range of it
+func downloadAll(g *CloudStorageGateway, fileID string, rng *ByteRange) ([]byte, error) {
+	body, _, err := g.DownloadFile(context.Background(), DownloadRequest{Token: "alice", FileID: fileID, Range: rng})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// testContent returns size bytes that differ from chunk to chunk
+func testContent(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7 / 13)
+	}
+	return data
+}
+
+// ============================================================================
+// Encryption Tests
+// ============================================================================
+
+func TestCloudStorageGateway_EncryptsAtRest(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store, WithEncryption(testKeyProvider(t, "k1", "k1")))
+	data := testContent(2*EncryptionChunkSize + 100)
+
+	fileID := uploadTestFile(t, gateway, "alice", data)
+
+	rec := store.records[fileID]
+	if rec.Encryption == nil || rec.Encryption.KeyID != "k1" || len(rec.Encryption.WrappedKey) == 0 || rec.Size != int64(len(data)) {
+		t.Fatalf("expected the wrapped key and plaintext size in the record, got %+v", rec)
+	}
+	stored := store.objects["my-bucket/"+fileID]
+	if len(stored) != len(data)+3*gcmTagSize || bytes.Contains(stored, data[:64]) {
+		t.Errorf("expected %d bytes of ciphertext, got %d bytes", len(data)+3*gcmTagSize, len(stored))
+	}
+
+	got, err := downloadAll(gateway, fileID, nil)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("download should return the plaintext, got %d bytes, %v", len(got), err)
+	}
+}
+
+func TestCloudStorageGateway_DecryptsRanges(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store, WithEncryption(testKeyProvider(t, "k1", "k1")))
+	data := testContent(3*EncryptionChunkSize + 10)
+	fileID := uploadTestFile(t, gateway, "alice", data)
+
+	tests := []struct {
+		name string
+		rng  ByteRange
+	}{
+		{name: "within first chunk", rng: ByteRange{Offset: 5, Length: 10}},
+		{name: "across chunks", rng: ByteRange{Offset: EncryptionChunkSize - 3, Length: EncryptionChunkSize + 6}},
+		{name: "final chunk", rng: ByteRange{Offset: 3*EncryptionChunkSize + 2}},
+		{name: "to the end", rng: ByteRange{Offset: EncryptionChunkSize}},
+		{name: "at the end", rng: ByteRange{Offset: int64(len(data))}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, _ := tt.rng.Bounds(int64(len(data)))
+
+			got, err := downloadAll(gateway, fileID, &tt.rng)
+
+			if err != nil || !bytes.Equal(got, data[start:end]) {
+				t.Errorf("expected bytes [%d, %d), got %d bytes, %v", start, end, len(got), err)
+			}
+		})
+	}
+
+	if _, err := downloadAll(gateway, fileID, &ByteRange{Offset: int64(len(data)) + 1}); !errors.Is(err, ErrInvalidRange) {
+		t.Errorf("expected ErrInvalidRange, got: %v", err)
+	}
+}
+
+func TestCloudStorageGateway_DetectsTamperedContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]byte) []byte
+	}{
+		{name: "flipped bit", tamper: func(b []byte) []byte { b[EncryptionChunkSize+20] ^= 1; return b }},
+		{name: "dropped final chunk", tamper: func(b []byte) []byte { return b[:EncryptionChunkSize+gcmTagSize] }},
+		{name: "swapped chunks", tamper: func(b []byte) []byte {
+			n := EncryptionChunkSize + gcmTagSize
+			return append(append(append([]byte{}, b[n:2*n]...), b[:n]...), b[2*n:]...)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemFileStore()
+			gateway := NewCloudStorageGateway(&countingAuthService{}, store, store, WithEncryption(testKeyProvider(t, "k1", "k1")))
+			fileID := uploadTestFile(t, gateway, "alice", testContent(2*EncryptionChunkSize+5))
+			store.objects["my-bucket/"+fileID] = tt.tamper(store.objects["my-bucket/"+fileID])
+
+			_, err := downloadAll(gateway, fileID, nil)
+
+			var storageErr *StorageError
+			if !errors.Is(err, ErrDecryptionFailed) || !errors.As(err, &storageErr) || storageErr.Op != "decrypt" {
+				t.Errorf("expected a decrypt StorageError, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestCloudStorageGateway_KeyRotation(t *testing.T) {
+	store := newMemFileStore()
+	old := NewCloudStorageGateway(&countingAuthService{}, store, store, WithEncryption(testKeyProvider(t, "k1", "k1")))
+	fileID := uploadTestFile(t, old, "alice", []byte("written under k1"))
+
+	rotated := NewCloudStorageGateway(&countingAuthService{}, store, store, WithEncryption(testKeyProvider(t, "k2", "k1", "k2")))
+	newID := uploadTestFile(t, rotated, "alice", []byte("written under k2"))
+
+	if got, err := downloadAll(rotated, fileID, nil); err != nil || string(got) != "written under k1" {
+		t.Errorf("retired keys must still unwrap, got %q, %v", got, err)
+	}
+	if store.records[newID].Encryption.KeyID != "k2" {
+		t.Errorf("new files should use the current key, got %q", store.records[newID].Encryption.KeyID)
+	}
+
+	withoutK1 := NewCloudStorageGateway(&countingAuthService{}, store, store, WithEncryption(testKeyProvider(t, "k2", "k2")))
+	if _, err := downloadAll(withoutK1, fileID, nil); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, got: %v", err)
+	}
+}
+
+func TestCloudStorageGateway_KeyProviderFailureIsNotStorageFailure(t *testing.T) {
+	store := newMemFileStore()
+	keyErr := NewKeyProviderError("generate", "k1", errors.New("kms throttled"), AsTemporary())
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store, WithEncryption(failingKeyProvider{err: keyErr}))
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{Token: "alice", FileName: "a.txt", Bucket: "my-bucket", Data: []byte("hello")})
+
+	var providerErr *KeyProviderError
+	var storageErr *StorageError
+	if !errors.As(err, &providerErr) || errors.As(err, &storageErr) {
+		t.Fatalf("expected only a KeyProviderError, got: %v", err)
+	}
+	if !IsTemporary(err) || Code(err) != CodeKeyProviderError {
+		t.Errorf("expected a temporary KEY_PROVIDER_ERROR, got %s: %v", Code(err), err)
+	}
+	if len(store.records) != 0 || len(store.objects) != 0 {
+		t.Error("nothing should be written when no data key is available")
+	}
+}
+
+func TestCloudStorageGateway_DownloadWithoutKeyProvider(t *testing.T) {
+	store := newMemFileStore()
+	fileID := uploadTestFile(t, NewCloudStorageGateway(&countingAuthService{}, store, store, WithEncryption(testKeyProvider(t, "k1", "k1"))), "alice", []byte("secret"))
+
+	_, err := downloadAll(NewCloudStorageGateway(&countingAuthService{}, store, store), fileID, nil)
+
+	if !errors.Is(err, ErrNoKeyProvider) {
+		t.Errorf("expected ErrNoKeyProvider, got: %v", err)
+	}
+}
+
+func TestCloudStorageGateway_EncryptedUploadVerifiesChecksum(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store, WithEncryption(testKeyProvider(t, "k1", "k1")))
+	sum, _ := ComputeChecksum(ChecksumSHA256, []byte("hello"))
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{Token: "alice", FileName: "a.txt", Bucket: "my-bucket", Data: []byte("hello"), Checksum: &sum})
+
+	if err != nil {
+		t.Errorf("the stored ciphertext should be checked against its own checksum, got: %v", err)
+	}
+}
+
+func TestCloudStorageGateway_EncryptsEmptyFiles(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store, WithEncryption(testKeyProvider(t, "k1", "k1")))
+
+	fileID := uploadTestFile(t, gateway, "alice", nil)
+
+	if got, err := downloadAll(gateway, fileID, nil); err != nil || len(got) != 0 {
+		t.Errorf("expected an empty download, got %q, %v", got, err)
+	}
+	if len(store.objects["my-bucket/"+fileID]) != gcmTagSize {
+		t.Error("empty content should still be sealed")
+	}
+}
+
+func TestCloudStorageGateway_EncryptedQuotaCountsCiphertext(t *testing.T) {
+	store := newMemFileStore()
+	quota := NewInMemoryQuotaService(map[string]int64{"my-bucket": 1 << 20})
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store, WithEncryption(testKeyProvider(t, "k1", "k1")), WithQuotaService(quota))
+
+	fileID := uploadTestFile(t, gateway, "alice", testContent(EncryptionChunkSize+1))
+
+	if got, want := quota.Usage("my-bucket"), int64(len(store.objects["my-bucket/"+fileID])); got != want {
+		t.Errorf("usage should match the stored ciphertext: got %d, want %d", got, want)
+	}
+	if err := gateway.DeleteFile(context.Background(), "alice", fileID); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := quota.Usage("my-bucket"); got != 0 {
+		t.Errorf("deleting the file should free all of it, usage is %d", got)
+	}
+}
+
+// ============================================================================
+// Key Provider Tests
+// ============================================================================
+
+func TestLocalKeyProvider_RejectsForeignWrappedKeys(t *testing.T) {
+	p := testKeyProvider(t, "k1", "k1", "k2")
+	dk, err := p.GenerateDataKey(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.UnwrapDataKey(context.Background(), "k2", dk.Wrapped); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("a key wrapped under k1 must not unwrap under k2, got: %v", err)
+	}
+	if key, err := p.UnwrapDataKey(context.Background(), "k1", dk.Wrapped); err != nil || !bytes.Equal(key, dk.Plaintext) {
+		t.Errorf("expected the data key back, got %v", err)
+	}
+}
+
+func TestNewLocalKeyProvider_Errors(t *testing.T) {
+	if _, err := NewLocalKeyProvider("missing", map[string][]byte{"k1": make([]byte, 32)}); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, got: %v", err)
+	}
+	var providerErr *KeyProviderError
+	if _, err := NewLocalKeyProvider("k1", map[string][]byte{"k1": make([]byte, 7)}); !errors.As(err, &providerErr) {
+		t.Errorf("expected a KeyProviderError for a bad key length, got: %v", err)
+	}
+}
+
+func TestKeyProviderError_LogValue(t *testing.T) {
+	var buf strings.Builder
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	logger.Error("upload", "error", NewKeyProviderError("unwrap", "k1", ErrKeyNotFound))
+
+	for _, want := range []string{"error.code=KEY_PROVIDER_KEY_NOT_FOUND", "error.op=unwrap", "error.key_id=k1"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log output missing %q: %s", want, buf.String())
+		}
+	}
+}
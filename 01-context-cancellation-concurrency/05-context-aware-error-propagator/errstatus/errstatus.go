@@ -0,0 +1,273 @@
+// Package errstatus maps propagator errors to transport status codes
+//
+// Handlers call FromError (or WriteProblem) instead of inspecting error types
+// themselves. The mapping walks the error chain with errors.Is/As, so it works
+// no matter how many layers wrapped the original failure. Response bodies only
+// ever contain fixed public messages: error strings, API keys and backend
+// details are never copied into them.
+package errstatus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	propagator "goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator"
+)
+
+// ============================================================================
+// Status Codes
+// ============================================================================
+
+// Code is a gRPC-style canonical status code
+// Values match google.golang.org/grpc/codes so they can be converted directly
+type Code uint32
+
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	AlreadyExists      Code = 6
+	PermissionDenied   Code = 7
+	ResourceExhausted  Code = 8
+	FailedPrecondition Code = 9
+	Aborted            Code = 10
+	OutOfRange         Code = 11
+	Unimplemented      Code = 12
+	Internal           Code = 13
+	Unavailable        Code = 14
+	DataLoss           Code = 15
+	Unauthenticated    Code = 16
+)
+
+var codeNames = [...]string{
+	OK:                 "OK",
+	Canceled:           "Canceled",
+	Unknown:            "Unknown",
+	InvalidArgument:    "InvalidArgument",
+	DeadlineExceeded:   "DeadlineExceeded",
+	NotFound:           "NotFound",
+	AlreadyExists:      "AlreadyExists",
+	PermissionDenied:   "PermissionDenied",
+	ResourceExhausted:  "ResourceExhausted",
+	FailedPrecondition: "FailedPrecondition",
+	Aborted:            "Aborted",
+	OutOfRange:         "OutOfRange",
+	Unimplemented:      "Unimplemented",
+	Internal:           "Internal",
+	Unavailable:        "Unavailable",
+	DataLoss:           "DataLoss",
+	Unauthenticated:    "Unauthenticated",
+}
+
+func (c Code) String() string {
+	if int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+	return "Code(" + strconv.FormatUint(uint64(c), 10) + ")"
+}
+
+// StatusClientClosedRequest is the non-standard HTTP status used when the caller went away
+const StatusClientClosedRequest = 499
+
+// DefaultRetryAfter is the hint given to clients for temporary failures
+// when no error in the chain carries its own RetryAfter
+const DefaultRetryAfter = time.Second
+
+// ============================================================================
+// Problem Details
+// ============================================================================
+
+// Problem is an RFC 9457 problem details object
+// Code is an extension member holding the stable propagator.ErrorCode
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code,omitempty"`
+}
+
+// Status is the transport-level view of an error
+type Status struct {
+	HTTPStatus int
+	Code       Code
+	RetryAfter time.Duration // Zero when the request should not be retried as-is
+	Problem    Problem
+}
+
+// Retryable reports whether clients may retry the request
+func (s Status) Retryable() bool {
+	return s.RetryAfter > 0
+}
+
+func newStatus(httpStatus int, code Code, retryAfter time.Duration, detail string) Status {
+	return Status{
+		HTTPStatus: httpStatus,
+		Code:       code,
+		RetryAfter: retryAfter,
+		Problem: Problem{
+			Type:   "about:blank",
+			Title:  statusText(httpStatus),
+			Status: httpStatus,
+			Detail: detail,
+		},
+	}
+}
+
+func statusText(httpStatus int) string {
+	if httpStatus == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(httpStatus)
+}
+
+// ============================================================================
+// Mapping
+// ============================================================================
+
+// FromError maps err to an HTTP status, a gRPC code and a problem body
+// A nil error maps to 200 / OK
+func FromError(err error) Status {
+	if err == nil {
+		return Status{HTTPStatus: http.StatusOK, Code: OK}
+	}
+	st := fromError(err)
+	st.Problem.Code = string(propagator.Code(err))
+	// Backends that know how long to wait override the default hint
+	if hint, ok := propagator.RetryAfterHint(err); ok && st.Retryable() {
+		st.RetryAfter = hint
+	}
+	return st
+}
+
+// fromError picks the status for a non-nil error, most specific match first
+func fromError(err error) Status {
+	// Caller-side cancellation wins over anything the backends reported
+	if errors.Is(err, context.Canceled) {
+		return newStatus(StatusClientClosedRequest, Canceled, 0, "The request was canceled.")
+	}
+
+	// Timeouts are checked before the typed errors: an auth call that timed out
+	// is a gateway problem, not bad credentials
+	if propagator.IsTimeout(err) {
+		return newStatus(http.StatusGatewayTimeout, DeadlineExceeded, DefaultRetryAfter, "A backend service did not respond in time.")
+	}
+
+	// Denials are final; a PermissionError never reports itself temporary
+	var permErr *propagator.PermissionError
+	if errors.As(err, &permErr) || errors.Is(err, propagator.ErrPermissionDenied) {
+		return newStatus(http.StatusForbidden, PermissionDenied, 0, "The action is not allowed.")
+	}
+
+	// Only a mismatch against the request is the client's fault; stored bytes
+	// that fail their checksum were corrupted by the backend
+	var integrityErr *propagator.IntegrityError
+	if errors.As(err, &integrityErr) && integrityErr.Op == "verify_stored" {
+		return newStatus(http.StatusInternalServerError, DataLoss, 0, "The stored content did not match its checksum.")
+	}
+	if integrityErr != nil || errors.Is(err, propagator.ErrChecksumMismatch) {
+		return newStatus(http.StatusUnprocessableEntity, DataLoss, 0, "The content did not match its checksum.")
+	}
+	if errors.Is(err, propagator.ErrUnsupportedChecksum) {
+		return newStatus(http.StatusBadRequest, InvalidArgument, 0, "The checksum algorithm is not supported.")
+	}
+
+	var validationErr *propagator.ValidationError
+	if errors.As(err, &validationErr) && validationErr.Violated(propagator.RuleMaxSize) {
+		return newStatus(http.StatusRequestEntityTooLarge, InvalidArgument, 0, "The file exceeds the size limit for this bucket.")
+	}
+	if errors.As(err, &validationErr) || errors.Is(err, propagator.ErrValidationFailed) {
+		return newStatus(http.StatusBadRequest, InvalidArgument, 0, "The upload did not pass validation.")
+	}
+
+	if errors.Is(err, propagator.ErrFileNotFound) || errors.Is(err, propagator.ErrObjectNotFound) {
+		return newStatus(http.StatusNotFound, NotFound, 0, "The file does not exist.")
+	}
+	if errors.Is(err, propagator.ErrObjectExists) || errors.Is(err, propagator.ErrKeyInUse) {
+		return newStatus(http.StatusConflict, AlreadyExists, 0, "An object with this key already exists.")
+	}
+	if errors.Is(err, propagator.ErrInvalidObjectName) {
+		return newStatus(http.StatusBadRequest, InvalidArgument, 0, "The bucket or key name is not valid.")
+	}
+	if errors.Is(err, propagator.ErrInvalidRange) {
+		return newStatus(http.StatusRequestedRangeNotSatisfiable, OutOfRange, 0, "The requested range is not satisfiable.")
+	}
+
+	if errors.Is(err, propagator.ErrInvalidStatusTransition) {
+		return newStatus(http.StatusConflict, FailedPrecondition, 0, "The file is not in a state that allows this operation.")
+	}
+
+	var quotaErr *propagator.StorageQuotaError
+	if errors.As(err, &quotaErr) || errors.Is(err, propagator.ErrQuotaExceeded) {
+		return newStatus(http.StatusInsufficientStorage, ResourceExhausted, 0, "The storage quota for this bucket has been exceeded.")
+	}
+
+	var authErr *propagator.AuthError
+	if errors.As(err, &authErr) || isAuthSentinel(err) {
+		if propagator.IsTemporary(err) {
+			return newStatus(http.StatusServiceUnavailable, Unavailable, DefaultRetryAfter, "The authentication service is temporarily unavailable.")
+		}
+		detail := "The credentials could not be validated."
+		if errors.Is(err, propagator.ErrTokenExpired) {
+			detail = "The token has expired."
+		}
+		return newStatus(http.StatusUnauthorized, Unauthenticated, 0, detail)
+	}
+
+	var keyErr *propagator.KeyProviderError
+	if errors.As(err, &keyErr) {
+		if propagator.IsTemporary(err) {
+			return newStatus(http.StatusServiceUnavailable, Unavailable, DefaultRetryAfter, "The key service is temporarily unavailable.")
+		}
+		return newStatus(http.StatusInternalServerError, Internal, 0, "The file's encryption key is unavailable.")
+	}
+	if errors.Is(err, propagator.ErrDecryptionFailed) {
+		return newStatus(http.StatusInternalServerError, DataLoss, 0, "The stored content failed its integrity check.")
+	}
+
+	var metaErr *propagator.MetadataError
+	if errors.As(err, &metaErr) || errors.Is(err, propagator.ErrDatabaseDeadlock) {
+		if propagator.IsTemporary(err) || errors.Is(err, propagator.ErrDatabaseDeadlock) {
+			return newStatus(http.StatusServiceUnavailable, Unavailable, DefaultRetryAfter, "The metadata service is temporarily unavailable.")
+		}
+		return newStatus(http.StatusInternalServerError, Internal, 0, "The metadata service failed.")
+	}
+
+	var storageErr *propagator.StorageError
+	if errors.As(err, &storageErr) || errors.Is(err, propagator.ErrStorageUnavailable) {
+		if propagator.IsTemporary(err) || errors.Is(err, propagator.ErrStorageUnavailable) {
+			return newStatus(http.StatusServiceUnavailable, Unavailable, DefaultRetryAfter, "The storage service is temporarily unavailable.")
+		}
+		return newStatus(http.StatusInternalServerError, Internal, 0, "The storage service failed.")
+	}
+
+	if propagator.IsTemporary(err) {
+		return newStatus(http.StatusServiceUnavailable, Unavailable, DefaultRetryAfter, "The service is temporarily unavailable.")
+	}
+	return newStatus(http.StatusInternalServerError, Unknown, 0, "An internal error occurred.")
+}
+
+func isAuthSentinel(err error) bool {
+	return errors.Is(err, propagator.ErrAuthFailed) ||
+		errors.Is(err, propagator.ErrTokenExpired) ||
+		errors.Is(err, propagator.ErrInvalidToken)
+}
+
+// WriteProblem writes err as an application/problem+json response
+// Retry-After is set (in whole seconds, rounded up) for retryable failures
+func WriteProblem(w http.ResponseWriter, err error) {
+	st := FromError(err)
+	if st.Retryable() {
+		w.Header().Set("Retry-After", strconv.FormatInt(int64((st.RetryAfter+time.Second-1)/time.Second), 10))
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(st.HTTPStatus)
+	_ = json.NewEncoder(w).Encode(st.Problem)
+}
@@ -0,0 +1,247 @@
+package errstatus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	propagator "goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator"
+)
+
+func TestFromError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		httpStatus int
+		code       Code
+		retryable  bool
+	}{
+		{
+			name:       "nil error",
+			err:        nil,
+			httpStatus: http.StatusOK,
+			code:       OK,
+		},
+		{
+			name:       "canceled",
+			err:        fmt.Errorf("upload: %w", context.Canceled),
+			httpStatus: StatusClientClosedRequest,
+			code:       Canceled,
+		},
+		{
+			name:       "wrapped deadline exceeded",
+			err:        propagator.WrapWithContext(&propagator.StorageError{Op: "upload", Err: context.DeadlineExceeded}, "gateway"),
+			httpStatus: http.StatusGatewayTimeout,
+			code:       DeadlineExceeded,
+			retryable:  true,
+		},
+		{
+			name:       "expired token",
+			err:        propagator.WrapWithContext(&propagator.AuthError{Op: "validate_token", Err: propagator.ErrTokenExpired}, "upload failed: auth"),
+			httpStatus: http.StatusUnauthorized,
+			code:       Unauthenticated,
+		},
+		{
+			name:       "bare invalid token sentinel",
+			err:        propagator.ErrInvalidToken,
+			httpStatus: http.StatusUnauthorized,
+			code:       Unauthenticated,
+		},
+		{
+			name:       "quota exceeded",
+			err:        propagator.WrapWithContext(&propagator.StorageQuotaError{Bucket: "b", CurrentUsage: 10, Limit: 5, Err: propagator.ErrQuotaExceeded}, "upload failed: quota"),
+			httpStatus: http.StatusInsufficientStorage,
+			code:       ResourceExhausted,
+		},
+		{
+			name:       "request checksum mismatch",
+			err:        &propagator.IntegrityError{Op: "verify_request", Err: propagator.ErrChecksumMismatch},
+			httpStatus: http.StatusUnprocessableEntity,
+			code:       DataLoss,
+		},
+		{
+			name:       "stored checksum mismatch",
+			err:        &propagator.IntegrityError{Op: "verify_stored", Err: propagator.ErrChecksumMismatch},
+			httpStatus: http.StatusInternalServerError,
+			code:       DataLoss,
+		},
+		{
+			name:       "database deadlock",
+			err:        &propagator.MetadataError{Op: "insert", Err: propagator.ErrDatabaseDeadlock},
+			httpStatus: http.StatusServiceUnavailable,
+			code:       Unavailable,
+			retryable:  true,
+		},
+		{
+			name:       "permanent metadata failure",
+			err:        &propagator.MetadataError{Op: "insert", Err: errors.New("constraint violation")},
+			httpStatus: http.StatusInternalServerError,
+			code:       Internal,
+		},
+		{
+			name:       "storage unavailable",
+			err:        &propagator.StorageError{Op: "upload", Err: propagator.ErrStorageUnavailable},
+			httpStatus: http.StatusServiceUnavailable,
+			code:       Unavailable,
+			retryable:  true,
+		},
+		{
+			name:       "permission denied",
+			err:        propagator.WrapWithContext(propagator.NewPermissionError("u", propagator.ActionUpload, "b"), "upload failed: permission"),
+			httpStatus: http.StatusForbidden,
+			code:       PermissionDenied,
+		},
+		{
+			name:       "file not found",
+			err:        propagator.WrapWithContext(propagator.NewMetadataError("get", "f", propagator.ErrFileNotFound), "download failed: metadata"),
+			httpStatus: http.StatusNotFound,
+			code:       NotFound,
+		},
+		{
+			name:       "invalid range",
+			err:        propagator.NewStorageError("download", "b", "k", propagator.ErrInvalidRange),
+			httpStatus: http.StatusRequestedRangeNotSatisfiable,
+			code:       OutOfRange,
+		},
+		{
+			name:       "object exists",
+			err:        propagator.NewStorageError("upload", "b", "u/k", propagator.ErrObjectExists),
+			httpStatus: http.StatusConflict,
+			code:       AlreadyExists,
+		},
+		{
+			name:       "key in use",
+			err:        propagator.NewMetadataError("insert", "", propagator.ErrKeyInUse),
+			httpStatus: http.StatusConflict,
+			code:       AlreadyExists,
+		},
+		{
+			name:       "invalid object name",
+			err:        propagator.NewStorageError("upload", "b", "../k", propagator.ErrInvalidObjectName),
+			httpStatus: http.StatusBadRequest,
+			code:       InvalidArgument,
+		},
+		{
+			name:       "invalid status transition",
+			err:        propagator.NewMetadataError("update", "f", propagator.ErrInvalidStatusTransition),
+			httpStatus: http.StatusConflict,
+			code:       FailedPrecondition,
+		},
+		{
+			name:       "validation failure",
+			err:        propagator.NewValidationError("b", "a.exe", []propagator.Violation{{Rule: propagator.RuleExtension, Message: "extension \".exe\" is not allowed"}}),
+			httpStatus: http.StatusBadRequest,
+			code:       InvalidArgument,
+		},
+		{
+			name:       "file too large",
+			err:        propagator.NewValidationError("b", "a.txt", []propagator.Violation{{Rule: propagator.RuleMaxSize, Message: "file is 11 bytes, the limit is 10"}}),
+			httpStatus: http.StatusRequestEntityTooLarge,
+			code:       InvalidArgument,
+		},
+		{
+			name:       "unknown error",
+			err:        errors.New("boom"),
+			httpStatus: http.StatusInternalServerError,
+			code:       Unknown,
+		},
+		{
+			name:       "temporary key provider failure",
+			err:        propagator.WrapWithContext(propagator.NewKeyProviderError("generate", "k1", errors.New("throttled"), propagator.AsTemporary()), "upload failed: encryption"),
+			httpStatus: http.StatusServiceUnavailable,
+			code:       Unavailable,
+			retryable:  true,
+		},
+		{
+			name:       "unknown encryption key",
+			err:        propagator.NewKeyProviderError("unwrap", "k1", propagator.ErrKeyNotFound),
+			httpStatus: http.StatusInternalServerError,
+			code:       Internal,
+		},
+		{
+			name:       "tampered ciphertext",
+			err:        propagator.NewStorageError("decrypt", "b", "k", propagator.ErrDecryptionFailed),
+			httpStatus: http.StatusInternalServerError,
+			code:       DataLoss,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := FromError(tt.err)
+			if st.HTTPStatus != tt.httpStatus {
+				t.Errorf("HTTPStatus = %d, want %d", st.HTTPStatus, tt.httpStatus)
+			}
+			if st.Code != tt.code {
+				t.Errorf("Code = %s, want %s", st.Code, tt.code)
+			}
+			if st.Retryable() != tt.retryable {
+				t.Errorf("Retryable() = %v, want %v", st.Retryable(), tt.retryable)
+			}
+		})
+	}
+}
+
+func TestWriteProblem_RetryAfterAndContentType(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteProblem(rec, &propagator.StorageError{Op: "upload", Err: propagator.ErrStorageUnavailable})
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("expected problem+json content type, got %q", got)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("expected Retry-After: 1, got %q", got)
+	}
+
+	var p Problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatalf("invalid problem body: %v", err)
+	}
+	if p.Status != http.StatusServiceUnavailable || p.Title != "Service Unavailable" {
+		t.Errorf("unexpected problem: %+v", p)
+	}
+}
+
+func TestWriteProblem_NeverLeaksInternals(t *testing.T) {
+	secret := "sk-super-secret-api-key-12345"
+	err := propagator.WrapWithContext(&propagator.AuthError{
+		Op:     "validate_token",
+		UserID: "user123",
+		APIKey: secret,
+		Err:    fmt.Errorf("db host 10.0.0.7 refused: %w", propagator.ErrAuthFailed),
+	}, "upload failed: auth")
+
+	rec := httptest.NewRecorder()
+	WriteProblem(rec, err)
+
+	body := rec.Body.String()
+	for _, leak := range []string{secret, "10.0.0.7", "user123", "validate_token"} {
+		if strings.Contains(body, leak) {
+			t.Errorf("problem body leaks %q: %s", leak, body)
+		}
+	}
+	if rec.Header().Get("Retry-After") != "" {
+		t.Error("permanent auth failures must not carry Retry-After")
+	}
+}
+
+func TestWriteProblem_UsesBackendRetryHint(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteProblem(rec, propagator.WrapWithContext(
+		propagator.NewStorageError("upload", "b", "k", propagator.ErrStorageUnavailable, propagator.WithRetryAfter(2500*time.Millisecond)),
+		"upload failed: storage",
+	))
+
+	if got := rec.Header().Get("Retry-After"); got != "3" {
+		t.Errorf("expected Retry-After rounded up to 3 seconds, got %q", got)
+	}
+}
@@ -0,0 +1,260 @@
+package propagator
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// ============================================================================
+// Upload Events
+// ============================================================================
+
+// EventType identifies what happened to an upload
+type EventType string
+
+// Events published by the gateway
+const (
+	EventUploadCompleted EventType = "upload.completed"
+	EventUploadFailed    EventType = "upload.failed"
+)
+
+// Event announces that an upload reached StatusCompleted or StatusFailed
+// ID is derived from the file ID and type, so consumers can use it to drop
+// the duplicates at-least-once delivery may produce
+type Event struct {
+	ID         string
+	Type       EventType
+	FileID     string
+	UserID     string
+	Bucket     string
+	Key        string // Object key the content was stored under
+	Size       int64
+	ErrorCode  ErrorCode // Code of the failure, for EventUploadFailed
+	OccurredAt time.Time
+}
+
+// newUploadEvent describes the final status of an upload; cause is nil on success
+func newUploadEvent(typ EventType, fileID, userID string, req FileUploadRequest, size int64, cause error) Event {
+	key := req.Key
+	if key == "" {
+		key = fileID
+	}
+	return Event{
+		ID:         fileID + "/" + string(typ),
+		Type:       typ,
+		FileID:     fileID,
+		UserID:     userID,
+		Bucket:     req.Bucket,
+		Key:        key,
+		Size:       size,
+		ErrorCode:  Code(cause),
+		OccurredAt: time.Now(),
+	}
+}
+
+// newRecordEvent describes a status change of an existing record, such as one made by the Reconciler
+func newRecordEvent(typ EventType, rec FileRecord, cause error) Event {
+	return Event{
+		ID:         rec.ID + "/" + string(typ),
+		Type:       typ,
+		FileID:     rec.ID,
+		UserID:     rec.UserID,
+		Bucket:     rec.Bucket,
+		Key:        rec.ObjectKey(),
+		Size:       rec.Size,
+		ErrorCode:  Code(cause),
+		OccurredAt: time.Now(),
+	}
+}
+
+// Publisher delivers events to downstream consumers
+type Publisher interface {
+	// Publish delivers ev; an error means it may not have been delivered
+	Publish(ctx context.Context, ev Event) error
+}
+
+// Outbox records events in the same write as a status update so neither can
+// be lost without the other. It must be backed by the same store as the
+// gateway's MetadataService, and an OutboxDispatcher delivers what it records
+type Outbox interface {
+	// UpdateFileStatusWithEvent applies UpdateFileStatus and records ev atomically
+	// Nothing is recorded when the record already has status. Returns
+	// MetadataError on failure, in which case neither change is made
+	UpdateFileStatusWithEvent(ctx context.Context, fileID string, status FileStatus, ev Event) error
+
+	// PendingEvents returns up to limit undelivered events, oldest first
+	PendingEvents(ctx context.Context, limit int) ([]Event, error)
+
+	// MarkEventsDelivered removes the events with the given IDs
+	// Unknown IDs are ignored
+	MarkEventsDelivered(ctx context.Context, ids ...string) error
+}
+
+// WithPublisher publishes an event after each upload completes or fails
+// Publishing is best effort: a failed Publish does not fail the upload and
+// the event is lost. Use WithOutbox when events must not be lost
+func WithPublisher(publisher Publisher) Option {
+	return func(g *CloudStorageGateway) {
+		g.publisher = publisher
+	}
+}
+
+// WithOutbox records an event with the final status update of each upload
+// Delivery is left to an OutboxDispatcher; a Publisher set with
+// WithPublisher is not used by the gateway in this mode
+func WithOutbox(outbox Outbox) Option {
+	return func(g *CloudStorageGateway) {
+		g.outbox = outbox
+	}
+}
+
+// finishUpload moves the record to its final status and announces it
+// With an Outbox the event is written together with the status; otherwise it
+// is published once the status update has succeeded
+func (g *CloudStorageGateway) finishUpload(ctx context.Context, status FileStatus, ev Event) error {
+	return updateStatusWithEvent(ctx, g.metadata, g.outbox, g.publisher, status, ev)
+}
+
+// updateStatusWithEvent moves ev's record to status and announces ev through
+// outbox or, without one, publisher; either may be nil
+func updateStatusWithEvent(ctx context.Context, metadata MetadataService, outbox Outbox, publisher Publisher, status FileStatus, ev Event) error {
+	if outbox != nil {
+		return outbox.UpdateFileStatusWithEvent(ctx, ev.FileID, status, ev)
+	}
+	if err := metadata.UpdateFileStatus(ctx, ev.FileID, status); err != nil {
+		return err
+	}
+	if publisher != nil {
+		_ = publisher.Publish(ctx, ev)
+	}
+	return nil
+}
+
+// ============================================================================
+// Outbox Dispatcher
+// ============================================================================
+
+// Defaults applied by NewOutboxDispatcher
+const (
+	DefaultDispatchInterval  = time.Second
+	DefaultDispatchBatchSize = 100
+)
+
+// OutboxDispatcher delivers recorded events to a Publisher at least once
+// Events are published oldest first and only marked delivered afterwards, so
+// a crash or a failed MarkEventsDelivered leads to redelivery, never to loss.
+// A failed Publish stops the pass so later events do not overtake it
+type OutboxDispatcher struct {
+	outbox    Outbox
+	publisher Publisher
+	logger    *slog.Logger
+	interval  time.Duration
+	batchSize int
+}
+
+// DispatcherOption configures an OutboxDispatcher
+type DispatcherOption func(*OutboxDispatcher)
+
+// WithDispatchInterval sets how often Run looks for undelivered events
+func WithDispatchInterval(interval time.Duration) DispatcherOption {
+	return func(d *OutboxDispatcher) {
+		d.interval = interval
+	}
+}
+
+// WithDispatchBatchSize sets how many events are fetched from the outbox at a time
+func WithDispatchBatchSize(n int) DispatcherOption {
+	return func(d *OutboxDispatcher) {
+		d.batchSize = n
+	}
+}
+
+// WithDispatchLogger sets the logger that reports delivery failures; slog.Default() is used otherwise
+func WithDispatchLogger(logger *slog.Logger) DispatcherOption {
+	return func(d *OutboxDispatcher) {
+		d.logger = logger
+	}
+}
+
+// NewOutboxDispatcher creates a dispatcher that moves events from outbox to publisher
+func NewOutboxDispatcher(outbox Outbox, publisher Publisher, opts ...DispatcherOption) *OutboxDispatcher {
+	d := &OutboxDispatcher{
+		outbox:    outbox,
+		publisher: publisher,
+		logger:    slog.Default(),
+		interval:  DefaultDispatchInterval,
+		batchSize: DefaultDispatchBatchSize,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.batchSize <= 0 {
+		d.batchSize = DefaultDispatchBatchSize
+	}
+	return d
+}
+
+// Run dispatches immediately and then on every interval until ctx is done
+// Failed passes are logged and retried on the next tick. Returns ctx's error once it is done
+func (d *OutboxDispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		_, _ = d.DispatchOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce publishes every pending event and returns how many were delivered
+// It stops at the first error, after marking the events published before it
+func (d *OutboxDispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	delivered := 0
+	for {
+		events, err := d.outbox.PendingEvents(ctx, d.batchSize)
+		if err != nil {
+			return delivered, d.fail(ctx, WrapWithContext(err, "dispatch failed: read outbox"))
+		}
+
+		ids := make([]string, 0, len(events))
+		var publishErr error
+		for _, ev := range events {
+			if publishErr = d.publisher.Publish(ctx, ev); publishErr != nil {
+				publishErr = WrapWithContext(publishErr, "dispatch failed: publish %s", ev.ID)
+				break
+			}
+			ids = append(ids, ev.ID)
+		}
+		if len(ids) > 0 {
+			if err := d.outbox.MarkEventsDelivered(ctx, ids...); err != nil {
+				// The events were published; they will be delivered again
+				return delivered, d.fail(ctx, WrapWithContext(err, "dispatch failed: mark delivered"))
+			}
+			delivered += len(ids)
+		}
+		if publishErr != nil {
+			return delivered, d.fail(ctx, publishErr)
+		}
+		if len(events) < d.batchSize {
+			if delivered > 0 {
+				d.logger.LogAttrs(ctx, slog.LevelDebug, "outbox dispatched", slog.Int("delivered", delivered))
+			}
+			return delivered, nil
+		}
+	}
+}
+
+// fail logs err at Warn when it is temporary and at Error otherwise, and returns it
+func (d *OutboxDispatcher) fail(ctx context.Context, err error) error {
+	level := slog.LevelError
+	if IsTemporary(err) {
+		level = slog.LevelWarn
+	}
+	d.logger.LogAttrs(ctx, level, "outbox dispatch failed", slog.Any("error", err))
+	return err
+}
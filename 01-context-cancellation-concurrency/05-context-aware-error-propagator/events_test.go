@@ -0,0 +1,154 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// recordingPublisher records published events and can fail them
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []Event
+	err    error
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, ev Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.events = append(p.events, ev)
+	return nil
+}
+
+// sliceOutbox is an Outbox over a slice of events
+type sliceOutbox struct {
+	events  []Event
+	markErr error
+}
+
+func (o *sliceOutbox) UpdateFileStatusWithEvent(ctx context.Context, fileID string, status FileStatus, ev Event) error {
+	o.events = append(o.events, ev)
+	return nil
+}
+
+func (o *sliceOutbox) PendingEvents(ctx context.Context, limit int) ([]Event, error) {
+	return slices.Clone(o.events[:min(limit, len(o.events))]), nil
+}
+
+func (o *sliceOutbox) MarkEventsDelivered(ctx context.Context, ids ...string) error {
+	if o.markErr != nil {
+		return o.markErr
+	}
+	o.events = slices.DeleteFunc(o.events, func(ev Event) bool { return slices.Contains(ids, ev.ID) })
+	return nil
+}
+
+// ============================================================================
+// Publisher Tests
+// ============================================================================
+
+func TestCloudStorageGateway_PublishesUploadCompleted(t *testing.T) {
+	publisher := &recordingPublisher{}
+	gateway := NewCloudStorageGateway(&mockAuthService{userID: "user123"}, &mockMetadataService{fileID: "file456"}, &mockStorageService{}, WithPublisher(publisher))
+
+	if err := gateway.UploadFile(context.Background(), FileUploadRequest{Token: "valid-token", FileName: "test.txt", Bucket: "my-bucket", Data: []byte("hello")}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(publisher.events) != 1 {
+		t.Fatalf("expected one event, got %+v", publisher.events)
+	}
+	ev := publisher.events[0]
+	if ev.Type != EventUploadCompleted || ev.ID != "file456/upload.completed" || ev.UserID != "user123" ||
+		ev.Bucket != "my-bucket" || ev.Key != "file456" || ev.Size != 5 || ev.ErrorCode != "" || ev.OccurredAt.IsZero() {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
+
+func TestCloudStorageGateway_PublishesUploadFailed(t *testing.T) {
+	publisher := &recordingPublisher{}
+	storageErr := NewStorageError("upload", "my-bucket", "file456", ErrStorageUnavailable, AsTemporary())
+	gateway := NewCloudStorageGateway(&mockAuthService{userID: "user123"}, &mockMetadataService{fileID: "file456"}, &mockStorageService{err: storageErr}, WithPublisher(publisher))
+
+	_ = gateway.UploadFile(context.Background(), FileUploadRequest{Token: "valid-token", FileName: "test.txt", Bucket: "my-bucket", Data: []byte("hello")})
+
+	if len(publisher.events) != 1 || publisher.events[0].Type != EventUploadFailed {
+		t.Fatalf("expected one failure event, got %+v", publisher.events)
+	}
+	if code := publisher.events[0].ErrorCode; code != CodeStorageUnavailable {
+		t.Errorf("ErrorCode = %s, want %s", code, CodeStorageUnavailable)
+	}
+}
+
+func TestCloudStorageGateway_PublishFailureDoesNotFailUpload(t *testing.T) {
+	publisher := &recordingPublisher{err: errors.New("broker down")}
+	gateway := NewCloudStorageGateway(&mockAuthService{userID: "user123"}, &mockMetadataService{fileID: "file456"}, &mockStorageService{}, WithPublisher(publisher))
+
+	if err := gateway.UploadFile(context.Background(), FileUploadRequest{Token: "valid-token", FileName: "test.txt", Bucket: "my-bucket", Data: []byte("hello")}); err != nil {
+		t.Errorf("publishing is best effort, got: %v", err)
+	}
+}
+
+func TestCloudStorageGateway_OutboxReplacesStatusUpdate(t *testing.T) {
+	outbox := &sliceOutbox{}
+	publisher := &recordingPublisher{}
+	metadata := &syncMetadataService{mockMetadataService: mockMetadataService{fileID: "file456"}}
+	gateway := NewCloudStorageGateway(&mockAuthService{userID: "user123"}, metadata, &mockStorageService{}, WithOutbox(outbox), WithPublisher(publisher))
+
+	if err := gateway.UploadFile(context.Background(), FileUploadRequest{Token: "valid-token", FileName: "test.txt", Bucket: "my-bucket", Data: []byte("hello")}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(outbox.events) != 1 || outbox.events[0].Type != EventUploadCompleted {
+		t.Errorf("expected the event in the outbox, got %+v", outbox.events)
+	}
+	if !slices.Equal(metadata.statuses, []FileStatus{StatusUploading}) {
+		t.Errorf("the final status should go through the outbox, got %v", metadata.statuses)
+	}
+	if len(publisher.events) != 0 {
+		t.Error("the gateway must leave publishing to the dispatcher in outbox mode")
+	}
+}
+
+// ============================================================================
+// Dispatcher Tests
+// ============================================================================
+
+func TestOutboxDispatcher_DeliversInBatches(t *testing.T) {
+	outbox := &sliceOutbox{}
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		outbox.events = append(outbox.events, Event{ID: id})
+	}
+	publisher := &recordingPublisher{}
+
+	n, err := NewOutboxDispatcher(outbox, publisher, WithDispatchBatchSize(2)).DispatchOnce(context.Background())
+
+	if err != nil || n != 5 {
+		t.Fatalf("DispatchOnce() = %d, %v; want 5", n, err)
+	}
+	if len(outbox.events) != 0 || len(publisher.events) != 5 || publisher.events[4].ID != "e" {
+		t.Errorf("expected every event delivered in order, got %+v", publisher.events)
+	}
+}
+
+func TestOutboxDispatcher_MarkFailureRedelivers(t *testing.T) {
+	outbox := &sliceOutbox{events: []Event{{ID: "a"}}, markErr: NewMetadataError("update", "", ErrDatabaseDeadlock, AsTemporary())}
+	publisher := &recordingPublisher{}
+	d := NewOutboxDispatcher(outbox, publisher)
+
+	if _, err := d.DispatchOnce(context.Background()); !errors.Is(err, ErrDatabaseDeadlock) || !IsTemporary(err) {
+		t.Fatalf("expected the temporary outbox failure, got: %v", err)
+	}
+	outbox.markErr = nil
+	if n, err := d.DispatchOnce(context.Background()); err != nil || n != 1 {
+		t.Fatalf("the next pass should deliver again, got %d, %v", n, err)
+	}
+	if len(publisher.events) != 2 {
+		t.Errorf("at-least-once delivery should repeat the event, got %+v", publisher.events)
+	}
+}
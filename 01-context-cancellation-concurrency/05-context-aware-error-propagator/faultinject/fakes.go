@@ -0,0 +1,308 @@
+package faultinject
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	propagator "goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator"
+)
+
+// ============================================================================
+// In-Memory Services
+// ============================================================================
+
+// Auth is an in-memory AuthService backed by a token table
+// It is safe for concurrent use
+type Auth struct {
+	mu      sync.Mutex
+	tokens  map[string]string
+	expired map[string]bool
+}
+
+// NewAuth creates an Auth accepting the given token -> userID table
+func NewAuth(tokens map[string]string) *Auth {
+	a := &Auth{tokens: make(map[string]string, len(tokens)), expired: make(map[string]bool)}
+	for token, userID := range tokens {
+		a.tokens[token] = userID
+	}
+	return a
+}
+
+// ExpireToken makes token fail with ErrTokenExpired from now on
+func (a *Auth) ExpireToken(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.expired[token] = true
+}
+
+func (a *Auth) ValidateToken(ctx context.Context, token string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	userID, ok := a.tokens[token]
+	switch {
+	case !ok:
+		return "", propagator.NewAuthError("validate_token", "", "", propagator.ErrInvalidToken)
+	case a.expired[token]:
+		return "", propagator.NewAuthError("validate_token", userID, "", propagator.ErrTokenExpired)
+	}
+	return userID, nil
+}
+
+// Metadata is an in-memory MetadataService that is also a propagator.Outbox
+// File IDs are assigned sequentially so listings are ordered by creation
+// It is safe for concurrent use
+type Metadata struct {
+	mu      sync.Mutex
+	records map[string]propagator.FileRecord
+	seq     int
+	events  []propagator.Event // Undelivered outbox events, oldest first
+}
+
+// NewMetadata creates an empty Metadata
+func NewMetadata() *Metadata {
+	return &Metadata{records: make(map[string]propagator.FileRecord)}
+}
+
+// Records returns a copy of every record
+func (m *Metadata) Records() map[string]propagator.FileRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]propagator.FileRecord, len(m.records))
+	for id, rec := range m.records {
+		out[id] = rec
+	}
+	return out
+}
+
+func (m *Metadata) CreateFileRecord(ctx context.Context, rec propagator.FileRecord) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, other := range m.records {
+		if rec.Key != "" && other.Bucket == rec.Bucket && other.Key == rec.Key {
+			return "", propagator.NewMetadataError("insert", "", propagator.ErrKeyInUse)
+		}
+	}
+	m.seq++
+	rec.ID = fmt.Sprintf("file-%06d", m.seq)
+	rec.Status = propagator.StatusPending
+	rec.CreatedAt = time.Now()
+	m.records[rec.ID] = rec
+	return rec.ID, nil
+}
+
+func (m *Metadata) UpdateFileStatus(ctx context.Context, fileID string, status propagator.FileStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := m.updateLocked(fileID, status)
+	return err
+}
+
+// UpdateFileStatusWithEvent updates the status and records ev in one step
+// Nothing is recorded when the status is already set
+func (m *Metadata) UpdateFileStatusWithEvent(ctx context.Context, fileID string, status propagator.FileStatus, ev propagator.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed, err := m.updateLocked(fileID, status)
+	if changed {
+		m.events = append(m.events, ev)
+	}
+	return err
+}
+
+// PendingEvents returns up to limit undelivered events, oldest first
+func (m *Metadata) PendingEvents(ctx context.Context, limit int) ([]propagator.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.events[:min(limit, len(m.events))]), nil
+}
+
+// MarkEventsDelivered removes the events with the given IDs
+func (m *Metadata) MarkEventsDelivered(ctx context.Context, ids ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = slices.DeleteFunc(m.events, func(ev propagator.Event) bool {
+		return slices.Contains(ids, ev.ID)
+	})
+	return nil
+}
+
+// updateLocked applies a status change with m.mu held and reports whether the status changed
+func (m *Metadata) updateLocked(fileID string, status propagator.FileStatus) (bool, error) {
+	rec, ok := m.records[fileID]
+	if !ok {
+		return false, propagator.NewMetadataError("update", fileID, propagator.ErrFileNotFound)
+	}
+	if err := propagator.CheckTransition(rec.Status, status); err != nil {
+		return false, propagator.NewMetadataError("update", fileID, err)
+	}
+	if rec.Status == status {
+		return false, nil
+	}
+	rec.Status = status
+	m.records[fileID] = rec
+	return true, nil
+}
+
+func (m *Metadata) GetFileRecord(ctx context.Context, fileID string) (propagator.FileRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.records[fileID]
+	if !ok {
+		return propagator.FileRecord{}, propagator.NewMetadataError("get", fileID, propagator.ErrFileNotFound)
+	}
+	return rec, nil
+}
+
+func (m *Metadata) DeleteFileRecord(ctx context.Context, fileID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.records[fileID]; !ok {
+		return propagator.NewMetadataError("delete", fileID, propagator.ErrFileNotFound)
+	}
+	delete(m.records, fileID)
+	return nil
+}
+
+func (m *Metadata) ListFileRecords(ctx context.Context, userID string, page propagator.PageRequest) (propagator.FileRecordPage, error) {
+	return m.list(page, func(rec propagator.FileRecord) bool { return rec.UserID == userID }), nil
+}
+
+func (m *Metadata) ListRecordsByStatus(ctx context.Context, status propagator.FileStatus, page propagator.PageRequest) (propagator.FileRecordPage, error) {
+	return m.list(page, func(rec propagator.FileRecord) bool { return rec.Status == status }), nil
+}
+
+// list returns one page of the records accepted by match, ordered by ID
+func (m *Metadata) list(page propagator.PageRequest, match func(propagator.FileRecord) bool) propagator.FileRecordPage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for id, rec := range m.records {
+		if match(rec) && id > page.Token {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var result propagator.FileRecordPage
+	if page.Size > 0 && len(ids) > page.Size {
+		ids = ids[:page.Size]
+		result.NextToken = ids[len(ids)-1]
+	}
+	for _, id := range ids {
+		result.Records = append(result.Records, m.records[id])
+	}
+	return result
+}
+
+// Storage is an in-memory StorageService
+// It is safe for concurrent use
+type Storage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+// NewStorage creates an empty Storage
+func NewStorage() *Storage {
+	return &Storage{objects: make(map[string][]byte)}
+}
+
+// Object returns a copy of the stored object
+func (s *Storage) Object(bucket, key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[bucket+"/"+key]
+	return bytes.Clone(data), ok
+}
+
+func (s *Storage) UploadFile(ctx context.Context, bucket, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[bucket+"/"+key] = bytes.Clone(data)
+	return nil
+}
+
+func (s *Storage) ObjectChecksum(ctx context.Context, bucket, key string, alg propagator.ChecksumAlgorithm) (propagator.Checksum, error) {
+	data, ok := s.Object(bucket, key)
+	if !ok {
+		return propagator.Checksum{}, propagator.NewStorageError("checksum", bucket, key, propagator.ErrObjectNotFound)
+	}
+	return propagator.ComputeChecksum(alg, data)
+}
+
+func (s *Storage) DownloadFile(ctx context.Context, bucket, key string, rng *propagator.ByteRange) (io.ReadCloser, error) {
+	data, ok := s.Object(bucket, key)
+	if !ok {
+		return nil, propagator.NewStorageError("download", bucket, key, propagator.ErrObjectNotFound)
+	}
+	if rng != nil {
+		start, end, err := rng.Bounds(int64(len(data)))
+		if err != nil {
+			return nil, propagator.NewStorageError("download", bucket, key, err)
+		}
+		data = data[start:end]
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *Storage) StatFile(ctx context.Context, bucket, key string) (propagator.ObjectInfo, error) {
+	data, ok := s.Object(bucket, key)
+	if !ok {
+		return propagator.ObjectInfo{}, propagator.NewStorageError("stat", bucket, key, propagator.ErrObjectNotFound)
+	}
+	return propagator.ObjectInfo{Size: int64(len(data))}, nil
+}
+
+func (s *Storage) DeleteFile(ctx context.Context, bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.objects[bucket+"/"+key]; !ok {
+		return propagator.NewStorageError("delete", bucket, key, propagator.ErrObjectNotFound)
+	}
+	delete(s.objects, bucket+"/"+key)
+	return nil
+}
+
+// Publisher is an in-memory propagator.Publisher that records what it receives
+// It is safe for concurrent use
+type Publisher struct {
+	mu       sync.Mutex
+	events   []propagator.Event
+	failures int
+	err      error
+}
+
+// NewPublisher creates a Publisher that accepts every event
+func NewPublisher() *Publisher {
+	return &Publisher{}
+}
+
+// FailNext makes the next n calls to Publish return err without recording the event
+func (p *Publisher) FailNext(n int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failures, p.err = n, err
+}
+
+// Events returns every published event in order, including duplicates
+func (p *Publisher) Events() []propagator.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.events)
+}
+
+func (p *Publisher) Publish(ctx context.Context, ev propagator.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures > 0 {
+		p.failures--
+		return p.err
+	}
+	p.events = append(p.events, ev)
+	return nil
+}
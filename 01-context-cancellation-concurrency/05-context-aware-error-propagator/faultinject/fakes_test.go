@@ -0,0 +1,112 @@
+package faultinject
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	propagator "goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator"
+)
+
+// ============================================================================
+// In-Memory Service Tests
+// ============================================================================
+
+func TestAuth_TokenStates(t *testing.T) {
+	auth := NewAuth(map[string]string{"tok": "alice"})
+	ctx := context.Background()
+
+	if userID, err := auth.ValidateToken(ctx, "tok"); err != nil || userID != "alice" {
+		t.Errorf("ValidateToken() = %q, %v", userID, err)
+	}
+	if _, err := auth.ValidateToken(ctx, "nope"); !errors.Is(err, propagator.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken, got: %v", err)
+	}
+	auth.ExpireToken("tok")
+	if _, err := auth.ValidateToken(ctx, "tok"); !errors.Is(err, propagator.ErrTokenExpired) {
+		t.Errorf("expected ErrTokenExpired, got: %v", err)
+	}
+}
+
+func TestInMemoryServices_GatewayRoundTrip(t *testing.T) {
+	metadata, storage := NewMetadata(), NewStorage()
+	gateway := propagator.NewCloudStorageGateway(NewAuth(map[string]string{"tok": "alice"}), metadata, storage)
+	ctx := context.Background()
+
+	results, err := gateway.UploadFiles(ctx, []propagator.FileUploadRequest{uploadRequest()})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	fileID := results[0].FileID
+
+	body, _, err := gateway.DownloadFile(ctx, propagator.DownloadRequest{Token: "tok", FileID: fileID, Range: &propagator.ByteRange{Offset: 1, Length: 3}})
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	got, _ := io.ReadAll(body)
+	body.Close()
+	if string(got) != "ell" {
+		t.Errorf("got %q, want %q", got, "ell")
+	}
+
+	if err := gateway.DeleteFile(ctx, "tok", fileID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if len(metadata.Records()) != 0 {
+		t.Error("record should be deleted")
+	}
+	if _, ok := storage.Object("b", fileID); ok {
+		t.Error("object should be deleted")
+	}
+}
+
+func TestMetadata_OutboxDeliversAtLeastOnce(t *testing.T) {
+	metadata, publisher := NewMetadata(), NewPublisher()
+	gateway := propagator.NewCloudStorageGateway(NewAuth(map[string]string{"tok": "alice"}), metadata, NewStorage(), propagator.WithOutbox(metadata))
+	ctx := context.Background()
+
+	for range 2 {
+		if err := gateway.UploadFile(ctx, propagator.FileUploadRequest{Token: "tok", FileName: "a.txt", Bucket: "b", Data: []byte("abc")}); err != nil {
+			t.Fatalf("upload failed: %v", err)
+		}
+	}
+	if pending, _ := metadata.PendingEvents(ctx, 10); len(pending) != 2 {
+		t.Fatalf("expected two recorded events, got %+v", pending)
+	}
+
+	dispatcher := propagator.NewOutboxDispatcher(metadata, publisher)
+	publisher.FailNext(1, propagator.NewStorageError("publish", "", "", propagator.ErrStorageUnavailable, propagator.AsTemporary()))
+	if _, err := dispatcher.DispatchOnce(ctx); !propagator.IsTemporary(err) {
+		t.Fatalf("expected the publish failure, got: %v", err)
+	}
+	if n, err := dispatcher.DispatchOnce(ctx); err != nil || n != 2 {
+		t.Fatalf("the next pass should deliver both events, got %d, %v", n, err)
+	}
+
+	events := publisher.Events()
+	if len(events) != 2 || events[0].Type != propagator.EventUploadCompleted || events[0].FileID != "file-000001" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+	if pending, _ := metadata.PendingEvents(ctx, 10); len(pending) != 0 {
+		t.Errorf("delivered events should leave the outbox, got %+v", pending)
+	}
+}
+
+func TestMetadata_OutboxSkipsUnchangedStatus(t *testing.T) {
+	metadata := NewMetadata()
+	ctx := context.Background()
+	id, _ := metadata.CreateFileRecord(ctx, propagator.FileRecord{UserID: "alice"})
+
+	ev := propagator.Event{ID: id + "/upload.failed", Type: propagator.EventUploadFailed, FileID: id}
+	_ = metadata.UpdateFileStatusWithEvent(ctx, id, propagator.StatusFailed, ev)
+	_ = metadata.UpdateFileStatusWithEvent(ctx, id, propagator.StatusFailed, ev)
+	err := metadata.UpdateFileStatusWithEvent(ctx, id, propagator.StatusCompleted, propagator.Event{ID: "late"})
+
+	if !errors.Is(err, propagator.ErrInvalidStatusTransition) {
+		t.Errorf("expected the refused transition, got: %v", err)
+	}
+	if pending, _ := metadata.PendingEvents(ctx, 10); len(pending) != 1 || pending[0].ID != ev.ID {
+		t.Errorf("only the real status change should record an event, got %+v", pending)
+	}
+}
@@ -0,0 +1,279 @@
+// Package faultinject provides scriptable test doubles for the gateway's services
+//
+// An Injector is a propagator.Interceptor driven by declarative Rules: fail
+// the Nth call, add latency, return a timeout or temporary error with some
+// probability, or block until the context is done. It records every call so
+// tests can assert on what the gateway did. Wrap any service with it, such as
+// the in-memory Auth, Metadata and Storage in this package:
+//
+//	inj := faultinject.New(faultinject.Rule{
+//		Service: propagator.ServiceStorage,
+//		Method:  "UploadFile",
+//		Nth:     1,
+//		Fault:   faultinject.FaultTemporary,
+//	})
+//	gateway := propagator.NewCloudStorageGateway(auth, metadata, storage,
+//		propagator.WithInterceptors(inj.Interceptor()))
+//
+// Injected errors are built with the propagator constructors, so they behave
+// like real backend failures under IsTimeout, IsTemporary and errors.Is/As.
+// Metadata doubles as an in-memory propagator.Outbox, and Publisher records
+// the events an OutboxDispatcher or the gateway delivers.
+package faultinject
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	propagator "goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator"
+)
+
+// ============================================================================
+// Rules
+// ============================================================================
+
+// Fault is what a firing Rule does to a call
+type Fault int
+
+const (
+	// FaultNone lets the call through, after the Rule's latency
+	FaultNone Fault = iota
+	// FaultError returns Rule.Err without calling the service
+	FaultError
+	// FaultTimeout returns a timeout error for the call's service
+	FaultTimeout
+	// FaultTemporary returns a temporary error for the call's service
+	FaultTemporary
+	// FaultBlock waits until the context is done and returns its error
+	FaultBlock
+)
+
+func (f Fault) String() string {
+	switch f {
+	case FaultNone:
+		return "none"
+	case FaultError:
+		return "error"
+	case FaultTimeout:
+		return "timeout"
+	case FaultTemporary:
+		return "temporary"
+	case FaultBlock:
+		return "block"
+	}
+	return "unknown"
+}
+
+// Rule describes when and how to inject a fault
+// A Rule matches calls by Service and Method; empty fields match anything.
+// Among matching calls it fires on the Nth one only (or on every one when Nth
+// is zero), and then only with the given Probability
+type Rule struct {
+	Service     string        // propagator.ServiceAuth, ServiceMetadata or ServiceStorage
+	Method      string        // Interface method name (e.g., "UploadFile")
+	Nth         int           // 1-based index of the matching call to fire on; zero fires on every call
+	Probability float64       // Chance of firing in (0, 1]; zero means always
+	Latency     time.Duration // Delay before the fault, or before the call for FaultNone
+	Fault       Fault
+	Err         error // Returned by FaultError
+}
+
+// matches reports whether the rule applies to call
+func (r Rule) matches(call propagator.Call) bool {
+	return (r.Service == "" || r.Service == call.Service) && (r.Method == "" || r.Method == call.Method)
+}
+
+// Record is one observed call
+type Record struct {
+	Call     propagator.Call
+	Fault    Fault // Injected fault; FaultNone if the call reached the service
+	Err      error
+	Duration time.Duration
+}
+
+// ============================================================================
+// Injector
+// ============================================================================
+
+// Injector applies Rules to intercepted calls and records every call
+// It is safe for concurrent use
+type Injector struct {
+	mu      sync.Mutex
+	rules   []Rule
+	counts  []int // Matching calls seen per rule
+	rng     *rand.Rand
+	records []Record
+}
+
+// New creates an Injector with the given rules
+// Probabilities are drawn from a fixed seed so runs are reproducible; use Seed to change it
+func New(rules ...Rule) *Injector {
+	inj := &Injector{rng: rand.New(rand.NewPCG(1, 2))}
+	inj.Add(rules...)
+	return inj
+}
+
+// Seed reseeds the random source used for Probability
+func (inj *Injector) Seed(seed uint64) {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	inj.rng = rand.New(rand.NewPCG(seed, seed))
+}
+
+// Add appends rules; for each call the first rule that fires wins
+func (inj *Injector) Add(rules ...Rule) {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	inj.rules = append(inj.rules, rules...)
+	inj.counts = append(inj.counts, make([]int, len(rules))...)
+}
+
+// Reset removes every rule and record
+func (inj *Injector) Reset() {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	inj.rules, inj.counts, inj.records = nil, nil, nil
+}
+
+// Records returns every call observed so far, in completion order
+func (inj *Injector) Records() []Record {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	return append([]Record(nil), inj.records...)
+}
+
+// Count returns how many calls matched service and method; empty values match anything
+func (inj *Injector) Count(service, method string) int {
+	match := Rule{Service: service, Method: method}
+	n := 0
+	for _, r := range inj.Records() {
+		if match.matches(r.Call) {
+			n++
+		}
+	}
+	return n
+}
+
+// Interceptor returns the propagator.Interceptor that applies the rules
+func (inj *Injector) Interceptor() propagator.Interceptor {
+	return func(ctx context.Context, call propagator.Call, invoke propagator.Invoker) error {
+		start := time.Now()
+		rule, fired := inj.fire(call)
+
+		var err error
+		fault := FaultNone
+		if fired {
+			fault = rule.Fault
+			err = inject(ctx, call, rule)
+		}
+		if fault == FaultNone && err == nil {
+			err = invoke(ctx)
+		}
+
+		inj.mu.Lock()
+		inj.records = append(inj.records, Record{Call: call, Fault: fault, Err: err, Duration: time.Since(start)})
+		inj.mu.Unlock()
+		return err
+	}
+}
+
+// Auth wraps next so its calls go through the injector
+func (inj *Injector) Auth(next propagator.AuthService) propagator.AuthService {
+	return propagator.InterceptAuth(next, inj.Interceptor())
+}
+
+// Metadata wraps next so its calls go through the injector
+func (inj *Injector) Metadata(next propagator.MetadataService) propagator.MetadataService {
+	return propagator.InterceptMetadata(next, inj.Interceptor())
+}
+
+// Storage wraps next so its calls go through the injector
+func (inj *Injector) Storage(next propagator.StorageService) propagator.StorageService {
+	return propagator.InterceptStorage(next, inj.Interceptor())
+}
+
+// fire returns the first rule that fires for call, advancing the per-rule counters
+func (inj *Injector) fire(call propagator.Call) (Rule, bool) {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+
+	var winner Rule
+	fired := false
+	for i, r := range inj.rules {
+		if !r.matches(call) {
+			continue
+		}
+		// Every matching rule counts the call, even once another has fired
+		inj.counts[i]++
+		if fired || (r.Nth > 0 && inj.counts[i] != r.Nth) {
+			continue
+		}
+		if r.Probability > 0 && inj.rng.Float64() >= r.Probability {
+			continue
+		}
+		winner, fired = r, true
+	}
+	return winner, fired
+}
+
+// inject waits out the rule's latency and returns the fault's error
+// It returns nil for FaultNone so the call proceeds
+func inject(ctx context.Context, call propagator.Call, rule Rule) error {
+	if rule.Latency > 0 {
+		t := time.NewTimer(rule.Latency)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return contextError(call, ctx.Err())
+		}
+	}
+
+	switch rule.Fault {
+	case FaultError:
+		return rule.Err
+	case FaultTimeout:
+		return serviceError(call, context.DeadlineExceeded, propagator.AsTimeout(), propagator.AsTemporary())
+	case FaultTemporary:
+		return serviceError(call, temporaryCause(call), propagator.AsTemporary())
+	case FaultBlock:
+		<-ctx.Done()
+		return contextError(call, ctx.Err())
+	}
+	return nil
+}
+
+// contextError reports a call cut short by its context
+// A deadline is a temporary timeout; a cancellation is neither
+func contextError(call propagator.Call, err error) error {
+	if err == context.DeadlineExceeded {
+		return serviceError(call, err, propagator.AsTimeout(), propagator.AsTemporary())
+	}
+	return serviceError(call, err)
+}
+
+// temporaryCause returns the sentinel a real backend of the call's service would report
+func temporaryCause(call propagator.Call) error {
+	switch call.Service {
+	case propagator.ServiceAuth:
+		return propagator.ErrAuthFailed
+	case propagator.ServiceMetadata:
+		return propagator.ErrDatabaseDeadlock
+	}
+	return propagator.ErrStorageUnavailable
+}
+
+// serviceError builds the typed error of the call's service
+// MetadataError has no timeout flag; wrapping context.DeadlineExceeded is what
+// makes IsTimeout report true for it
+func serviceError(call propagator.Call, err error, opts ...propagator.ErrorOption) error {
+	switch call.Service {
+	case propagator.ServiceAuth:
+		return propagator.NewAuthError(call.Method, "", "", err, opts...)
+	case propagator.ServiceMetadata:
+		return propagator.NewMetadataError(call.Method, call.FileID, err, opts...)
+	}
+	return propagator.NewStorageError(call.Method, call.Bucket, call.Key, err, opts...)
+}
@@ -0,0 +1,152 @@
+package faultinject
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	propagator "goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator"
+)
+
+// newGateway wires the in-memory services through inj
+func newGateway(inj *Injector, opts ...propagator.Option) (*propagator.CloudStorageGateway, *Metadata, *Storage) {
+	metadata, storage := NewMetadata(), NewStorage()
+	opts = append([]propagator.Option{propagator.WithInterceptors(inj.Interceptor())}, opts...)
+	gateway := propagator.NewCloudStorageGateway(NewAuth(map[string]string{"tok": "alice"}), metadata, storage, opts...)
+	return gateway, metadata, storage
+}
+
+func uploadRequest() propagator.FileUploadRequest {
+	return propagator.FileUploadRequest{Token: "tok", FileName: "a.txt", Bucket: "b", Data: []byte("hello")}
+}
+
+// ============================================================================
+// Rule Tests
+// ============================================================================
+
+func TestInjector_FailsNthCall(t *testing.T) {
+	boom := errors.New("boom")
+	inj := New(Rule{Service: propagator.ServiceStorage, Method: "UploadFile", Nth: 2, Fault: FaultError, Err: boom})
+	gateway, _, _ := newGateway(inj)
+
+	for i, wantErr := range []bool{false, true, false} {
+		err := gateway.UploadFile(context.Background(), uploadRequest())
+		if (err != nil) != wantErr {
+			t.Errorf("upload %d: err = %v, want error %v", i+1, err, wantErr)
+		}
+		if wantErr && !errors.Is(err, boom) {
+			t.Errorf("upload %d: expected the scripted error, got: %v", i+1, err)
+		}
+	}
+	if got := inj.Count(propagator.ServiceStorage, "UploadFile"); got != 3 {
+		t.Errorf("expected 3 recorded uploads, got %d", got)
+	}
+}
+
+func TestInjector_TimeoutAndTemporaryFaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		service   string
+		fault     Fault
+		timeout   bool
+		temporary bool
+		target    any
+	}{
+		{name: "storage timeout", service: propagator.ServiceStorage, fault: FaultTimeout, timeout: true, temporary: true, target: new(*propagator.StorageError)},
+		{name: "metadata timeout", service: propagator.ServiceMetadata, fault: FaultTimeout, timeout: true, temporary: true, target: new(*propagator.MetadataError)},
+		{name: "auth temporary", service: propagator.ServiceAuth, fault: FaultTemporary, temporary: true, target: new(*propagator.AuthError)},
+		{name: "metadata temporary", service: propagator.ServiceMetadata, fault: FaultTemporary, temporary: true, target: new(*propagator.MetadataError)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway, _, _ := newGateway(New(Rule{Service: tt.service, Fault: tt.fault}))
+
+			err := gateway.UploadFile(context.Background(), uploadRequest())
+
+			if propagator.IsTimeout(err) != tt.timeout || propagator.IsTemporary(err) != tt.temporary {
+				t.Errorf("IsTimeout = %v, IsTemporary = %v for %v", propagator.IsTimeout(err), propagator.IsTemporary(err), err)
+			}
+			if !errors.As(err, tt.target) {
+				t.Errorf("expected the service's typed error, got: %T %v", err, err)
+			}
+			if tt.fault == FaultTimeout && !errors.Is(err, context.DeadlineExceeded) {
+				t.Error("timeouts should wrap context.DeadlineExceeded")
+			}
+		})
+	}
+}
+
+func TestInjector_Probability(t *testing.T) {
+	inj := New(Rule{Service: propagator.ServiceStorage, Probability: 0.3, Fault: FaultTemporary})
+	gateway, _, _ := newGateway(inj)
+
+	failures := 0
+	for range 200 {
+		if gateway.UploadFile(context.Background(), uploadRequest()) != nil {
+			failures++
+		}
+	}
+	if failures < 30 || failures > 90 {
+		t.Errorf("expected roughly 30%% of 200 uploads to fail, got %d", failures)
+	}
+}
+
+func TestInjector_BlockUntilDeadline(t *testing.T) {
+	inj := New(Rule{Service: propagator.ServiceMetadata, Method: "CreateFileRecord", Fault: FaultBlock})
+	gateway, _, storage := newGateway(inj)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := gateway.UploadFile(ctx, uploadRequest())
+
+	if !propagator.IsTimeout(err) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("a blocked call should end as a timeout, got: %v", err)
+	}
+	if _, ok := storage.Object("b", "file-000001"); ok {
+		t.Error("storage must not be reached when metadata blocks")
+	}
+	recs := inj.Records()
+	if len(recs) != 2 || recs[1].Fault != FaultBlock || recs[1].Duration < 20*time.Millisecond {
+		t.Errorf("unexpected records: %+v", recs)
+	}
+}
+
+func TestInjector_LatencyThenCall(t *testing.T) {
+	inj := New(Rule{Service: propagator.ServiceAuth, Latency: 20 * time.Millisecond})
+	gateway, _, _ := newGateway(inj)
+
+	start := time.Now()
+	if err := gateway.UploadFile(context.Background(), uploadRequest()); err != nil {
+		t.Fatalf("latency alone should not fail the call, got: %v", err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Error("expected the injected latency")
+	}
+}
+
+func TestInjector_ResilienceScenario(t *testing.T) {
+	// The first status update deadlocks; a retried upload must succeed and
+	// leave exactly one completed record behind the failed one
+	inj := New(Rule{Service: propagator.ServiceMetadata, Method: "UpdateFileStatus", Nth: 1, Fault: FaultTemporary})
+	gateway, metadata, _ := newGateway(inj)
+
+	err := propagator.Retry(context.Background(), propagator.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}, func(ctx context.Context) error {
+		return gateway.UploadFile(ctx, uploadRequest())
+	})
+
+	if err != nil {
+		t.Fatalf("expected the retry to succeed, got: %v", err)
+	}
+	statuses := map[propagator.FileStatus]int{}
+	for _, rec := range metadata.Records() {
+		statuses[rec.Status]++
+	}
+	if statuses[propagator.StatusCompleted] != 1 {
+		t.Errorf("expected one completed record, got %v", statuses)
+	}
+	if got := inj.Count(propagator.ServiceMetadata, "CreateFileRecord"); got != 2 {
+		t.Errorf("expected 2 record creations, got %d", got)
+	}
+}
@@ -0,0 +1,296 @@
+// Package filemeta is a MetadataService persisted to a single JSON file
+//
+// Every change is written to a temporary file, fsynced and renamed over the
+// previous version, so the file on disk is always a complete snapshot. The
+// store is meant for one process: writers are serialized by an in-process
+// lock, and waiting longer than the lock timeout is reported the way a
+// database reports a deadlock, as a temporary MetadataError wrapping
+// ErrDatabaseDeadlock. Status changes are checked with
+// propagator.CheckTransition and refused with a StatusTransitionError.
+package filemeta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	propagator "goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator"
+)
+
+// DefaultLockTimeout is how long an operation waits for the store lock
+const DefaultLockTimeout = time.Second
+
+// Store is a file-backed MetadataService
+// It is safe for concurrent use
+type Store struct {
+	path        string
+	lockTimeout time.Duration
+	now         func() time.Time
+
+	lock    chan struct{} // Holds one token while an operation runs
+	seq     int
+	records map[string]propagator.FileRecord
+}
+
+// snapshot is the on-disk format
+type snapshot struct {
+	Seq     int                     `json:"seq"`
+	Records []propagator.FileRecord `json:"records"`
+}
+
+// Option configures a Store
+type Option func(*Store)
+
+// WithLockTimeout sets how long an operation waits for the store lock
+func WithLockTimeout(d time.Duration) Option {
+	return func(s *Store) {
+		s.lockTimeout = d
+	}
+}
+
+// WithClock replaces time.Now for record timestamps, for tests
+func WithClock(now func() time.Time) Option {
+	return func(s *Store) {
+		s.now = now
+	}
+}
+
+// Open loads the store at path, starting empty if the file does not exist
+func Open(path string, opts ...Option) (*Store, error) {
+	s := &Store{
+		path:        path,
+		lockTimeout: DefaultLockTimeout,
+		now:         time.Now,
+		lock:        make(chan struct{}, 1),
+		records:     make(map[string]propagator.FileRecord),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("filemeta: read %s: %w", path, err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("filemeta: decode %s: %w", path, err)
+	}
+	s.seq = snap.Seq
+	for _, rec := range snap.Records {
+		s.records[rec.ID] = rec
+	}
+	return s, nil
+}
+
+// CreateFileRecord stores rec as a new pending record and returns its generated ID
+// A key already used by another record in the bucket is refused with ErrKeyInUse
+func (s *Store) CreateFileRecord(ctx context.Context, rec propagator.FileRecord) (string, error) {
+	if err := s.acquire(ctx, "insert", ""); err != nil {
+		return "", err
+	}
+	defer s.release()
+
+	if rec.Key != "" {
+		for _, other := range s.records {
+			if other.Bucket == rec.Bucket && other.Key == rec.Key {
+				return "", propagator.NewMetadataError("insert", "", propagator.ErrKeyInUse)
+			}
+		}
+	}
+
+	s.seq++
+	rec.ID = fmt.Sprintf("file-%08d", s.seq)
+	rec.Status = propagator.StatusPending
+	rec.CreatedAt = s.now()
+	s.records[rec.ID] = rec
+
+	if err := s.persist(); err != nil {
+		delete(s.records, rec.ID)
+		s.seq--
+		return "", propagator.NewMetadataError("insert", rec.ID, err)
+	}
+	return rec.ID, nil
+}
+
+// UpdateFileStatus changes the status of fileID
+// Setting the current status again is a no-op; other changes must be allowed
+// by propagator.CheckTransition
+func (s *Store) UpdateFileStatus(ctx context.Context, fileID string, status propagator.FileStatus) error {
+	if err := s.acquire(ctx, "update", fileID); err != nil {
+		return err
+	}
+	defer s.release()
+
+	rec, ok := s.records[fileID]
+	if !ok {
+		return propagator.NewMetadataError("update", fileID, propagator.ErrFileNotFound)
+	}
+	if rec.Status == status {
+		return nil
+	}
+	if err := propagator.CheckTransition(rec.Status, status); err != nil {
+		return propagator.NewMetadataError("update", fileID, err)
+	}
+
+	prev := rec
+	rec.Status = status
+	s.records[fileID] = rec
+	if err := s.persist(); err != nil {
+		s.records[fileID] = prev
+		return propagator.NewMetadataError("update", fileID, err)
+	}
+	return nil
+}
+
+// GetFileRecord returns the record for fileID
+func (s *Store) GetFileRecord(ctx context.Context, fileID string) (propagator.FileRecord, error) {
+	if err := s.acquire(ctx, "query", fileID); err != nil {
+		return propagator.FileRecord{}, err
+	}
+	defer s.release()
+
+	rec, ok := s.records[fileID]
+	if !ok {
+		return propagator.FileRecord{}, propagator.NewMetadataError("query", fileID, propagator.ErrFileNotFound)
+	}
+	return rec, nil
+}
+
+// DeleteFileRecord removes the record for fileID
+func (s *Store) DeleteFileRecord(ctx context.Context, fileID string) error {
+	if err := s.acquire(ctx, "delete", fileID); err != nil {
+		return err
+	}
+	defer s.release()
+
+	rec, ok := s.records[fileID]
+	if !ok {
+		return propagator.NewMetadataError("delete", fileID, propagator.ErrFileNotFound)
+	}
+	delete(s.records, fileID)
+	if err := s.persist(); err != nil {
+		s.records[fileID] = rec
+		return propagator.NewMetadataError("delete", fileID, err)
+	}
+	return nil
+}
+
+// ListFileRecords returns one page of userID's records, ordered by ID
+func (s *Store) ListFileRecords(ctx context.Context, userID string, page propagator.PageRequest) (propagator.FileRecordPage, error) {
+	return s.list(ctx, page, func(rec propagator.FileRecord) bool { return rec.UserID == userID })
+}
+
+// ListRecordsByStatus returns one page of the records in status across all users, ordered by ID
+func (s *Store) ListRecordsByStatus(ctx context.Context, status propagator.FileStatus, page propagator.PageRequest) (propagator.FileRecordPage, error) {
+	return s.list(ctx, page, func(rec propagator.FileRecord) bool { return rec.Status == status })
+}
+
+// list returns one page of the records accepted by match, ordered by ID
+func (s *Store) list(ctx context.Context, page propagator.PageRequest, match func(propagator.FileRecord) bool) (propagator.FileRecordPage, error) {
+	if err := s.acquire(ctx, "query", ""); err != nil {
+		return propagator.FileRecordPage{}, err
+	}
+	defer s.release()
+
+	var ids []string
+	for id, rec := range s.records {
+		if match(rec) && id > page.Token {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var result propagator.FileRecordPage
+	if page.Size > 0 && len(ids) > page.Size {
+		ids = ids[:page.Size]
+		result.NextToken = ids[len(ids)-1]
+	}
+	for _, id := range ids {
+		result.Records = append(result.Records, s.records[id])
+	}
+	return result, nil
+}
+
+// acquire takes the store lock, waiting at most the lock timeout
+// Contention is reported as a temporary deadlock; a cancelled or expired ctx
+// is reported as such
+func (s *Store) acquire(ctx context.Context, op, fileID string) error {
+	select {
+	case s.lock <- struct{}{}:
+		return nil
+	default:
+	}
+
+	t := time.NewTimer(s.lockTimeout)
+	defer t.Stop()
+	select {
+	case s.lock <- struct{}{}:
+		return nil
+	case <-t.C:
+		return propagator.NewMetadataError(op, fileID,
+			fmt.Errorf("%w: lock not acquired within %v", propagator.ErrDatabaseDeadlock, s.lockTimeout),
+			propagator.AsTemporary())
+	case <-ctx.Done():
+		var opts []propagator.ErrorOption
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			opts = append(opts, propagator.AsTemporary())
+		}
+		return propagator.NewMetadataError(op, fileID, ctx.Err(), opts...)
+	}
+}
+
+func (s *Store) release() {
+	<-s.lock
+}
+
+// persist atomically replaces the file with the current records; the lock must be held
+func (s *Store) persist() error {
+	snap := snapshot{Seq: s.seq, Records: make([]propagator.FileRecord, 0, len(s.records))}
+	for _, rec := range s.records {
+		snap.Records = append(snap.Records, rec)
+	}
+	sort.Slice(snap.Records, func(i, j int) bool { return snap.Records[i].ID < snap.Records[j].ID })
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,267 @@
+package filemeta
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	propagator "goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator"
+	"goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator/faultinject"
+)
+
+func openStore(t *testing.T, opts ...Option) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "meta.json")
+	s, err := Open(path, opts...)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	return s, path
+}
+
+func createRecord(t *testing.T, s *Store, userID string) string {
+	t.Helper()
+	id, err := s.CreateFileRecord(context.Background(), propagator.FileRecord{UserID: userID, FileName: "a.txt", Bucket: "b", Size: 3})
+	if err != nil {
+		t.Fatalf("CreateFileRecord() failed: %v", err)
+	}
+	return id
+}
+
+// ============================================================================
+// Record Tests
+// ============================================================================
+
+func TestStore_PersistsAcrossReopen(t *testing.T) {
+	s, path := openStore(t)
+	ctx := context.Background()
+	id := createRecord(t, s, "alice")
+	if err := s.UpdateFileStatus(ctx, id, propagator.StatusUploading); err != nil {
+		t.Fatalf("UpdateFileStatus() failed: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	rec, err := reopened.GetFileRecord(ctx, id)
+	if err != nil {
+		t.Fatalf("GetFileRecord() failed: %v", err)
+	}
+	if rec.Status != propagator.StatusUploading || rec.UserID != "alice" || rec.Bucket != "b" || rec.CreatedAt.IsZero() {
+		t.Errorf("unexpected record after reopen: %+v", rec)
+	}
+	if next := createRecord(t, reopened, "alice"); next <= id {
+		t.Errorf("IDs must keep increasing after reopen, got %s after %s", next, id)
+	}
+}
+
+func TestStore_StatusTransitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  []propagator.FileStatus
+		valid bool
+	}{
+		{name: "upload completes", path: []propagator.FileStatus{propagator.StatusUploading, propagator.StatusCompleted}, valid: true},
+		{name: "pending to failed", path: []propagator.FileStatus{propagator.StatusFailed}, valid: true},
+		{name: "same status is a no-op", path: []propagator.FileStatus{propagator.StatusUploading, propagator.StatusUploading}, valid: true},
+		{name: "completed to deleted", path: []propagator.FileStatus{propagator.StatusUploading, propagator.StatusCompleted, propagator.StatusDeleted}, valid: true},
+		{name: "pending skips uploading", path: []propagator.FileStatus{propagator.StatusCompleted}},
+		{name: "completed to failed", path: []propagator.FileStatus{propagator.StatusUploading, propagator.StatusCompleted, propagator.StatusFailed}},
+		{name: "deleted is final", path: []propagator.FileStatus{propagator.StatusDeleted, propagator.StatusCompleted}},
+		{name: "back to pending", path: []propagator.FileStatus{propagator.StatusFailed, propagator.StatusPending}},
+		{name: "unknown status", path: []propagator.FileStatus{"archived"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := openStore(t)
+			id := createRecord(t, s, "alice")
+
+			var err error
+			for _, status := range tt.path {
+				if err = s.UpdateFileStatus(context.Background(), id, status); err != nil {
+					break
+				}
+			}
+			if tt.valid {
+				if err != nil {
+					t.Errorf("expected the transitions to succeed, got: %v", err)
+				}
+				return
+			}
+			var metaErr *propagator.MetadataError
+			var transitionErr *propagator.StatusTransitionError
+			if !errors.As(err, &metaErr) || !errors.As(err, &transitionErr) || !errors.Is(err, propagator.ErrInvalidStatusTransition) {
+				t.Errorf("expected MetadataError wrapping a StatusTransitionError, got: %v", err)
+			}
+			if propagator.IsTemporary(err) {
+				t.Error("an invalid transition is permanent")
+			}
+		})
+	}
+}
+
+func TestStore_NotFound(t *testing.T) {
+	s, _ := openStore(t)
+	ctx := context.Background()
+
+	if _, err := s.GetFileRecord(ctx, "missing"); !errors.Is(err, propagator.ErrFileNotFound) {
+		t.Errorf("GetFileRecord: expected ErrFileNotFound, got: %v", err)
+	}
+	if err := s.UpdateFileStatus(ctx, "missing", propagator.StatusUploading); !errors.Is(err, propagator.ErrFileNotFound) {
+		t.Errorf("UpdateFileStatus: expected ErrFileNotFound, got: %v", err)
+	}
+	if err := s.DeleteFileRecord(ctx, "missing"); !errors.Is(err, propagator.ErrFileNotFound) {
+		t.Errorf("DeleteFileRecord: expected ErrFileNotFound, got: %v", err)
+	}
+}
+
+func TestStore_KeysAreUniquePerBucket(t *testing.T) {
+	s, _ := openStore(t)
+	ctx := context.Background()
+	rec := propagator.FileRecord{UserID: "alice", FileName: "a.txt", Bucket: "b", Key: "alice/k"}
+	id, err := s.CreateFileRecord(ctx, rec)
+	if err != nil {
+		t.Fatalf("CreateFileRecord() failed: %v", err)
+	}
+	_ = s.UpdateFileStatus(ctx, id, propagator.StatusDeleted)
+
+	if _, err := s.CreateFileRecord(ctx, rec); !errors.Is(err, propagator.ErrKeyInUse) {
+		t.Errorf("a tombstone should keep its key, got: %v", err)
+	}
+	other := rec
+	other.Bucket = "other"
+	if _, err := s.CreateFileRecord(ctx, other); err != nil {
+		t.Errorf("the same key in another bucket should be accepted, got: %v", err)
+	}
+
+	_ = s.DeleteFileRecord(ctx, id)
+	if _, err := s.CreateFileRecord(ctx, rec); err != nil {
+		t.Errorf("the key should be free once the record is removed, got: %v", err)
+	}
+}
+
+func TestStore_ListPaginates(t *testing.T) {
+	s, _ := openStore(t)
+	for range 3 {
+		createRecord(t, s, "alice")
+	}
+	createRecord(t, s, "bob")
+
+	first, err := s.ListFileRecords(context.Background(), "alice", propagator.PageRequest{Size: 2})
+	if err != nil || len(first.Records) != 2 || first.NextToken == "" {
+		t.Fatalf("first page = %+v, %v", first, err)
+	}
+	second, err := s.ListFileRecords(context.Background(), "alice", propagator.PageRequest{Size: 2, Token: first.NextToken})
+	if err != nil || len(second.Records) != 1 || second.NextToken != "" {
+		t.Errorf("second page = %+v, %v", second, err)
+	}
+}
+
+func TestStore_ListRecordsByStatus(t *testing.T) {
+	s, _ := openStore(t)
+	ctx := context.Background()
+	pending := createRecord(t, s, "alice")
+	uploading := createRecord(t, s, "bob")
+	_ = s.UpdateFileStatus(ctx, uploading, propagator.StatusUploading)
+
+	page, err := s.ListRecordsByStatus(ctx, propagator.StatusPending, propagator.PageRequest{})
+	if err != nil || len(page.Records) != 1 || page.Records[0].ID != pending {
+		t.Errorf("pending records = %+v, %v", page, err)
+	}
+	page, err = s.ListRecordsByStatus(ctx, propagator.StatusUploading, propagator.PageRequest{})
+	if err != nil || len(page.Records) != 1 || page.Records[0].UserID != "bob" {
+		t.Errorf("records of every user should be listed, got %+v, %v", page, err)
+	}
+}
+
+// ============================================================================
+// Lock Contention Tests
+// ============================================================================
+
+func TestStore_LockContentionIsTemporaryDeadlock(t *testing.T) {
+	s, _ := openStore(t, WithLockTimeout(10*time.Millisecond))
+	id := createRecord(t, s, "alice")
+
+	s.lock <- struct{}{} // Another operation holds the lock
+	defer s.release()
+
+	err := s.UpdateFileStatus(context.Background(), id, propagator.StatusUploading)
+
+	var metaErr *propagator.MetadataError
+	if !errors.As(err, &metaErr) || metaErr.FileID != id || metaErr.Op != "update" {
+		t.Fatalf("expected MetadataError for the update, got: %v", err)
+	}
+	if !errors.Is(err, propagator.ErrDatabaseDeadlock) || !propagator.IsTemporary(err) {
+		t.Errorf("contention should be a temporary deadlock, got: %v", err)
+	}
+}
+
+func TestStore_LockWaitHonorsContext(t *testing.T) {
+	s, _ := openStore(t, WithLockTimeout(time.Minute))
+	s.lock <- struct{}{}
+	defer s.release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := s.CreateFileRecord(ctx, propagator.FileRecord{UserID: "alice"})
+
+	if !errors.Is(err, context.DeadlineExceeded) || !propagator.IsTimeout(err) {
+		t.Errorf("expected the caller's deadline, got: %v", err)
+	}
+}
+
+func TestStore_RetryAfterContention(t *testing.T) {
+	s, _ := openStore(t, WithLockTimeout(5*time.Millisecond))
+	s.lock <- struct{}{}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.release()
+	}()
+
+	policy := propagator.RetryPolicy{MaxAttempts: 10, BaseDelay: 5 * time.Millisecond}
+	err := propagator.Retry(context.Background(), policy, func(ctx context.Context) error {
+		_, err := s.CreateFileRecord(ctx, propagator.FileRecord{UserID: "alice"})
+		return err
+	})
+
+	if err != nil {
+		t.Errorf("Retry should ride out the contention, got: %v", err)
+	}
+}
+
+// ============================================================================
+// Gateway Integration
+// ============================================================================
+
+// staticAuth accepts every token as the same user
+type staticAuth struct{}
+
+func (staticAuth) ValidateToken(ctx context.Context, token string) (string, error) {
+	return "alice", nil
+}
+
+func TestStore_GatewayFlowsFollowTransitions(t *testing.T) {
+	s, _ := openStore(t)
+	storage := faultinject.NewStorage()
+	inj := faultinject.New(faultinject.Rule{Service: propagator.ServiceStorage, Method: "UploadFile", Nth: 2, Fault: faultinject.FaultTemporary})
+	gateway := propagator.NewCloudStorageGateway(staticAuth{}, s, inj.Storage(storage))
+	ctx := context.Background()
+	req := propagator.FileUploadRequest{Token: "tok", FileName: "a.txt", Bucket: "b", Data: []byte("abc")}
+
+	results, err := gateway.UploadFiles(ctx, []propagator.FileUploadRequest{req, req}, propagator.WithBatchConcurrency(1))
+	if results[0].Err != nil || !propagator.IsTemporary(err) {
+		t.Fatalf("expected the second upload to fail temporarily, got: %v", err)
+	}
+	if err := gateway.DeleteFile(ctx, "tok", results[0].FileID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	page, _ := s.ListFileRecords(ctx, "alice", propagator.PageRequest{})
+	if len(page.Records) != 1 || page.Records[0].Status != propagator.StatusFailed {
+		t.Errorf("expected only the failed record to remain, got %+v", page.Records)
+	}
+}
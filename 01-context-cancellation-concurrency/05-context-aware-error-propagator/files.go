@@ -0,0 +1,241 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ============================================================================
+// File Records
+// ============================================================================
+
+// FileRecord is the metadata entry for an uploaded file
+type FileRecord struct {
+	ID        string
+	UserID    string
+	FileName  string
+	Bucket    string
+	Key       string // Object key under the owner's namespace; empty means the file ID is the key
+	Size      int64
+	Status    FileStatus
+	CreatedAt time.Time
+
+	// Encryption describes how the stored content is encrypted; nil when it
+	// is stored as uploaded. Size is always the size of the plaintext
+	Encryption *Encryption
+}
+
+// ObjectKey returns the key the file content is stored under
+func (r FileRecord) ObjectKey() string {
+	if r.Key != "" {
+		return r.Key
+	}
+	return r.ID
+}
+
+// storedSize returns how many bytes the file's object takes in storage
+func (r FileRecord) storedSize() int64 {
+	if r.Encryption != nil {
+		return sealedSize(r.Size)
+	}
+	return r.Size
+}
+
+// newFileRecord describes the record an upload request creates
+func newFileRecord(userID string, req FileUploadRequest, size int64) FileRecord {
+	return FileRecord{
+		UserID:   userID,
+		FileName: req.FileName,
+		Bucket:   req.Bucket,
+		Key:      req.Key,
+		Size:     size,
+	}
+}
+
+// Page sizes applied by ListFiles
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// PageRequest selects one page of a listing
+type PageRequest struct {
+	Size  int    // Maximum number of records; DefaultPageSize when zero
+	Token string // NextToken of the previous page; empty for the first page
+}
+
+// FileRecordPage is one page of a listing
+type FileRecordPage struct {
+	Records   []FileRecord
+	NextToken string // Empty on the last page
+}
+
+// ByteRange selects part of an object
+type ByteRange struct {
+	Offset int64
+	Length int64 // Zero reads to the end of the object
+}
+
+// Bounds resolves the range against an object of the given size, returning
+// the half-open interval [start, end)
+// Returns ErrInvalidRange if the range does not fit the object
+func (r ByteRange) Bounds(size int64) (start, end int64, err error) {
+	if r.Offset < 0 || r.Length < 0 || r.Offset > size {
+		return 0, 0, fmt.Errorf("%w: offset %d length %d for size %d", ErrInvalidRange, r.Offset, r.Length, size)
+	}
+	end = size
+	if r.Length > 0 && r.Offset+r.Length < size {
+		end = r.Offset + r.Length
+	}
+	return r.Offset, end, nil
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Size int64
+}
+
+// Sentinel errors for file lookups and object keys
+var (
+	ErrFileNotFound   = NewSentinel(CodeFileNotFound, "file not found")
+	ErrObjectNotFound = NewSentinel(CodeObjectNotFound, "object not found")
+	ErrObjectExists   = NewSentinel(CodeObjectExists, "object already exists")
+	ErrKeyInUse       = NewSentinel(CodeKeyInUse, "object key already in use")
+	ErrInvalidRange   = NewSentinel(CodeInvalidRange, "invalid byte range")
+	// ErrInvalidObjectName is reported by storage backends for bucket or key names they cannot store
+	ErrInvalidObjectName = NewSentinel(CodeInvalidObjectName, "invalid bucket or key name")
+)
+
+// ============================================================================
+// Download, Delete and List
+// ============================================================================
+
+// DownloadRequest represents a file download request
+type DownloadRequest struct {
+	Token  string
+	FileID string
+	Range  *ByteRange // Optional; nil downloads the whole file
+}
+
+// DownloadFile streams a completed file owned by the caller
+// The caller must close the returned reader. Files owned by another user or
+// not completed yet are reported as ErrFileNotFound so their existence is not revealed
+// Encrypted files are decrypted while they are read; content that fails
+// authentication makes Read return a StorageError wrapping ErrDecryptionFailed
+func (g *CloudStorageGateway) DownloadFile(ctx context.Context, req DownloadRequest) (io.ReadCloser, FileRecord, error) {
+	userID, err := g.auth.ValidateToken(ctx, req.Token)
+	if err != nil {
+		return nil, FileRecord{}, WrapWithContext(err, "download failed: auth")
+	}
+
+	rec, err := g.ownedRecord(ctx, userID, req.FileID)
+	if err == nil && rec.Status != StatusCompleted {
+		err = NewMetadataError("get", req.FileID, ErrFileNotFound)
+	}
+	if err != nil {
+		return nil, FileRecord{}, WrapWithContext(err, "download failed: metadata")
+	}
+	if err := g.authorize(ctx, userID, ActionDownload, rec.Bucket); err != nil {
+		return nil, FileRecord{}, WrapWithContext(err, "download failed: permission")
+	}
+
+	if rec.Encryption != nil {
+		body, err := g.decryptDownload(ctx, rec, req.Range)
+		if err != nil {
+			return nil, FileRecord{}, WrapWithContext(err, "download failed: decryption")
+		}
+		return body, rec, nil
+	}
+	body, err := g.storage.DownloadFile(ctx, rec.Bucket, rec.ObjectKey(), req.Range)
+	if err != nil {
+		return nil, FileRecord{}, WrapWithContext(err, "download failed: storage")
+	}
+	return body, rec, nil
+}
+
+// DeleteFile removes a file owned by the caller, both its object and its record
+// Files still uploading are refused with a StatusTransitionError. The record is
+// marked deleted first, so a file whose object is going away is never served;
+// the object is then deleted and the record removed. If either step fails the
+// record stays behind as a deleted tombstone and calling DeleteFile again
+// finishes the job. A completed file's bytes are freed from the bucket quota
+// when its tombstone is written, so retries never free them twice
+// Records keep their key until they are removed (see CreateFileRecord), so the
+// object deleted here never belongs to another file
+func (g *CloudStorageGateway) DeleteFile(ctx context.Context, token, fileID string) error {
+	userID, err := g.auth.ValidateToken(ctx, token)
+	if err != nil {
+		return WrapWithContext(err, "delete failed: auth")
+	}
+
+	rec, err := g.ownedRecord(ctx, userID, fileID)
+	if err != nil {
+		return WrapWithContext(err, "delete failed: metadata")
+	}
+	if err := g.authorize(ctx, userID, ActionDelete, rec.Bucket); err != nil {
+		return WrapWithContext(err, "delete failed: permission")
+	}
+
+	if err := CheckTransition(rec.Status, StatusDeleted); err != nil {
+		return WrapWithContext(NewMetadataError("update", fileID, err), "delete failed: status")
+	}
+
+	if rec.Status != StatusDeleted {
+		if err := g.metadata.UpdateFileStatus(ctx, fileID, StatusDeleted); err != nil {
+			return WrapWithContext(err, "delete failed: status update")
+		}
+		// Failed uploads already released their reservation
+		if rec.Status == StatusCompleted {
+			g.freeQuota(ctx, rec.Bucket, rec.storedSize())
+		}
+	}
+
+	// A missing object means an earlier attempt already deleted it
+	if err := g.storage.DeleteFile(ctx, rec.Bucket, rec.ObjectKey()); err != nil && !errors.Is(err, ErrObjectNotFound) {
+		return WrapWithContext(err, "delete failed: storage")
+	}
+
+	if err := g.metadata.DeleteFileRecord(ctx, fileID); err != nil && !errors.Is(err, ErrFileNotFound) {
+		return WrapWithContext(err, "delete failed: metadata")
+	}
+	return nil
+}
+
+// ListFiles returns one page of the caller's files, including failed and deleted ones
+// page.Size defaults to DefaultPageSize and is capped at MaxPageSize
+func (g *CloudStorageGateway) ListFiles(ctx context.Context, token string, page PageRequest) (FileRecordPage, error) {
+	userID, err := g.auth.ValidateToken(ctx, token)
+	if err != nil {
+		return FileRecordPage{}, WrapWithContext(err, "list failed: auth")
+	}
+	if err := g.authorize(ctx, userID, ActionList, ""); err != nil {
+		return FileRecordPage{}, WrapWithContext(err, "list failed: permission")
+	}
+
+	if page.Size <= 0 {
+		page.Size = DefaultPageSize
+	}
+	page.Size = min(page.Size, MaxPageSize)
+
+	result, err := g.metadata.ListFileRecords(ctx, userID, page)
+	if err != nil {
+		return FileRecordPage{}, WrapWithContext(err, "list failed: metadata")
+	}
+	return result, nil
+}
+
+// ownedRecord fetches fileID and checks that it belongs to userID
+// Records of other users are reported as missing
+func (g *CloudStorageGateway) ownedRecord(ctx context.Context, userID, fileID string) (FileRecord, error) {
+	rec, err := g.metadata.GetFileRecord(ctx, fileID)
+	if err != nil {
+		return FileRecord{}, err
+	}
+	if rec.UserID != userID {
+		return FileRecord{}, NewMetadataError("get", fileID, ErrFileNotFound)
+	}
+	return rec, nil
+}
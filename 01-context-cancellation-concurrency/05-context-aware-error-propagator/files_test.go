@@ -0,0 +1,495 @@
+package propagator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memFileStore is an in-memory MetadataService and StorageService
+type memFileStore struct {
+	mu              sync.Mutex
+	records         map[string]FileRecord
+	objects         map[string][]byte
+	nextID          int
+	uploadErr       error
+	deleteObjectErr error
+	deleteRecordErr error
+}
+
+func newMemFileStore() *memFileStore {
+	return &memFileStore{records: make(map[string]FileRecord), objects: make(map[string][]byte)}
+}
+
+func (m *memFileStore) CreateFileRecord(ctx context.Context, rec FileRecord) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, other := range m.records {
+		if rec.Key != "" && other.Bucket == rec.Bucket && other.Key == rec.Key {
+			return "", NewMetadataError("insert", "", ErrKeyInUse)
+		}
+	}
+	m.nextID++
+	rec.ID = fmt.Sprintf("file-%03d", m.nextID)
+	rec.Status = StatusPending
+	rec.CreatedAt = time.Now()
+	m.records[rec.ID] = rec
+	return rec.ID, nil
+}
+
+func (m *memFileStore) UpdateFileStatus(ctx context.Context, fileID string, status FileStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.records[fileID]
+	if !ok {
+		return NewMetadataError("update", fileID, ErrFileNotFound)
+	}
+	if err := CheckTransition(rec.Status, status); err != nil {
+		return NewMetadataError("update", fileID, err)
+	}
+	rec.Status = status
+	m.records[fileID] = rec
+	return nil
+}
+
+func (m *memFileStore) GetFileRecord(ctx context.Context, fileID string) (FileRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.records[fileID]
+	if !ok {
+		return FileRecord{}, NewMetadataError("get", fileID, ErrFileNotFound)
+	}
+	return rec, nil
+}
+
+func (m *memFileStore) DeleteFileRecord(ctx context.Context, fileID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.deleteRecordErr != nil {
+		return m.deleteRecordErr
+	}
+	if _, ok := m.records[fileID]; !ok {
+		return NewMetadataError("delete", fileID, ErrFileNotFound)
+	}
+	delete(m.records, fileID)
+	return nil
+}
+
+func (m *memFileStore) ListFileRecords(ctx context.Context, userID string, page PageRequest) (FileRecordPage, error) {
+	return m.list(page, func(rec FileRecord) bool { return rec.UserID == userID }), nil
+}
+
+func (m *memFileStore) ListRecordsByStatus(ctx context.Context, status FileStatus, page PageRequest) (FileRecordPage, error) {
+	return m.list(page, func(rec FileRecord) bool { return rec.Status == status }), nil
+}
+
+func (m *memFileStore) list(page PageRequest, match func(FileRecord) bool) FileRecordPage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for id, rec := range m.records {
+		if match(rec) && id > page.Token {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var result FileRecordPage
+	if len(ids) > page.Size {
+		ids = ids[:page.Size]
+		result.NextToken = ids[len(ids)-1]
+	}
+	for _, id := range ids {
+		result.Records = append(result.Records, m.records[id])
+	}
+	return result
+}
+
+func (m *memFileStore) UploadFile(ctx context.Context, bucket, key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.uploadErr != nil {
+		return m.uploadErr
+	}
+	m.objects[bucket+"/"+key] = bytes.Clone(data)
+	return nil
+}
+
+func (m *memFileStore) ObjectChecksum(ctx context.Context, bucket, key string, alg ChecksumAlgorithm) (Checksum, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return ComputeChecksum(alg, m.objects[bucket+"/"+key])
+}
+
+func (m *memFileStore) DownloadFile(ctx context.Context, bucket, key string, rng *ByteRange) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[bucket+"/"+key]
+	if !ok {
+		return nil, NewStorageError("download", bucket, key, ErrObjectNotFound)
+	}
+	if rng != nil {
+		start, end, err := rng.Bounds(int64(len(data)))
+		if err != nil {
+			return nil, NewStorageError("download", bucket, key, err)
+		}
+		data = data[start:end]
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memFileStore) StatFile(ctx context.Context, bucket, key string) (ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[bucket+"/"+key]
+	if !ok {
+		return ObjectInfo{}, NewStorageError("stat", bucket, key, ErrObjectNotFound)
+	}
+	return ObjectInfo{Size: int64(len(data))}, nil
+}
+
+func (m *memFileStore) DeleteFile(ctx context.Context, bucket, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.deleteObjectErr != nil {
+		return m.deleteObjectErr
+	}
+	if _, ok := m.objects[bucket+"/"+key]; !ok {
+		return NewStorageError("delete", bucket, key, ErrObjectNotFound)
+	}
+	delete(m.objects, bucket+"/"+key)
+	return nil
+}
+
+// uploadTestFile uploads data for the user identified by token and returns its file ID
+func uploadTestFile(t *testing.T, g *CloudStorageGateway, token string, data []byte) string {
+	t.Helper()
+	results, err := g.UploadFiles(context.Background(), []FileUploadRequest{{
+		Token:    token,
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     data,
+	}})
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	return results[0].FileID
+}
+
+// ============================================================================
+// Byte Range Tests
+// ============================================================================
+
+func TestByteRange_Bounds(t *testing.T) {
+	tests := []struct {
+		name      string
+		rng       ByteRange
+		start     int64
+		end       int64
+		wantError bool
+	}{
+		{name: "whole object", rng: ByteRange{}, start: 0, end: 10},
+		{name: "prefix", rng: ByteRange{Length: 4}, start: 0, end: 4},
+		{name: "suffix", rng: ByteRange{Offset: 6}, start: 6, end: 10},
+		{name: "length past the end is clamped", rng: ByteRange{Offset: 8, Length: 5}, start: 8, end: 10},
+		{name: "offset past the end", rng: ByteRange{Offset: 11}, wantError: true},
+		{name: "negative offset", rng: ByteRange{Offset: -1}, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := tt.rng.Bounds(10)
+			if tt.wantError {
+				if !errors.Is(err, ErrInvalidRange) {
+					t.Errorf("expected ErrInvalidRange, got: %v", err)
+				}
+				return
+			}
+			if err != nil || start != tt.start || end != tt.end {
+				t.Errorf("Bounds() = %d, %d, %v; want %d, %d", start, end, err, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+// ============================================================================
+// Download Tests
+// ============================================================================
+
+func TestCloudStorageGateway_DownloadFile_Range(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store)
+	fileID := uploadTestFile(t, gateway, "alice", []byte("hello world"))
+
+	body, rec, err := gateway.DownloadFile(context.Background(), DownloadRequest{
+		Token:  "alice",
+		FileID: fileID,
+		Range:  &ByteRange{Offset: 6, Length: 5},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer body.Close()
+
+	got, _ := io.ReadAll(body)
+	if string(got) != "world" {
+		t.Errorf("got %q, want %q", got, "world")
+	}
+	if rec.ID != fileID || rec.FileName != "test.txt" || rec.Size != 11 {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+}
+
+func TestCloudStorageGateway_DownloadFile_HidesOtherUsersFiles(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store)
+	fileID := uploadTestFile(t, gateway, "alice", []byte("hello world"))
+
+	_, _, err := gateway.DownloadFile(context.Background(), DownloadRequest{Token: "mallory", FileID: fileID})
+
+	var metaErr *MetadataError
+	if !errors.As(err, &metaErr) || !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("another user's file should look missing, got: %v", err)
+	}
+}
+
+func TestCloudStorageGateway_DownloadFile_InvalidRange(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store)
+	fileID := uploadTestFile(t, gateway, "alice", []byte("hello world"))
+
+	_, _, err := gateway.DownloadFile(context.Background(), DownloadRequest{
+		Token:  "alice",
+		FileID: fileID,
+		Range:  &ByteRange{Offset: 100},
+	})
+
+	var storageErr *StorageError
+	if !errors.As(err, &storageErr) || !errors.Is(err, ErrInvalidRange) {
+		t.Errorf("expected StorageError wrapping ErrInvalidRange, got: %v", err)
+	}
+}
+
+// ============================================================================
+// Client Key Tests
+// ============================================================================
+
+// uploadWithKey uploads data for token under the client key
+func uploadWithKey(g *CloudStorageGateway, token, key string, data []byte) (UploadResult, error) {
+	return g.UploadFileResult(context.Background(), FileUploadRequest{Token: token, FileName: "a.txt", Bucket: "my-bucket", Data: data, Key: key})
+}
+
+func TestCloudStorageGateway_ClientKeysAreNamespacedPerUser(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store)
+	bobFile := uploadTestFile(t, gateway, "bob", []byte("bob's file"))
+
+	alice, err := uploadWithKey(gateway, "alice", "report", []byte("alice's report"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	bob, err := uploadWithKey(gateway, "bob", "report", []byte("bob's report"))
+	if err != nil {
+		t.Fatalf("the same key of another user should not conflict, got: %v", err)
+	}
+	if _, err := uploadWithKey(gateway, "alice", bobFile, []byte("overwrite")); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if alice.Key != ClientObjectKey("user-alice", "report") || bob.Key != ClientObjectKey("user-bob", "report") {
+		t.Errorf("expected keys under each user, got %q and %q", alice.Key, bob.Key)
+	}
+	if got := string(store.objects["my-bucket/"+bobFile]); got != "bob's file" {
+		t.Errorf("a client key must never reach a file ID key, got %q", got)
+	}
+}
+
+func TestCloudStorageGateway_FailedRecordKeepsItsKey(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store)
+	store.uploadErr = NewStorageError("upload", "my-bucket", ClientObjectKey("user-alice", "k"), ErrStorageUnavailable, AsTemporary())
+	if _, err := uploadWithKey(gateway, "alice", "k", []byte("first")); err == nil {
+		t.Fatal("expected the first upload to fail")
+	}
+	store.uploadErr = nil
+	var failedID string
+	for id := range store.records {
+		failedID = id
+	}
+
+	_, err := uploadWithKey(gateway, "alice", "k", []byte("retry"))
+	if !errors.Is(err, ErrKeyInUse) {
+		t.Fatalf("the failed record still owns the key, expected ErrKeyInUse, got: %v", err)
+	}
+
+	if err := gateway.DeleteFile(context.Background(), "alice", failedID); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	retry, err := uploadWithKey(gateway, "alice", "k", []byte("retry"))
+	if err != nil {
+		t.Fatalf("the key should be free once the failed record is deleted, got: %v", err)
+	}
+	if got := string(store.objects["my-bucket/"+retry.Key]); got != "retry" {
+		t.Errorf("expected the retried content under the key, got %q", got)
+	}
+}
+
+func TestCloudStorageGateway_ClientKeyRefusesOverwrite(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store)
+	first, err := uploadWithKey(gateway, "alice", "report", []byte("first"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	_, err = uploadWithKey(gateway, "alice", "report", []byte("second"))
+
+	if !errors.Is(err, ErrObjectExists) || Code(err) != CodeObjectExists {
+		t.Errorf("expected ErrObjectExists, got: %v", err)
+	}
+	if got := string(store.objects["my-bucket/"+first.Key]); got != "first" {
+		t.Errorf("the existing object must be kept, got %q", got)
+	}
+	if len(store.records) != 1 {
+		t.Errorf("no record should be created for a refused key: %v", store.records)
+	}
+
+	if err := gateway.DeleteFile(context.Background(), "alice", first.FileID); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := uploadWithKey(gateway, "alice", "report", []byte("second")); err != nil {
+		t.Errorf("the key should be free again after the delete, got: %v", err)
+	}
+}
+
+// ============================================================================
+// Delete Tests
+// ============================================================================
+
+func TestCloudStorageGateway_DeleteFile(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store)
+	fileID := uploadTestFile(t, gateway, "alice", []byte("hello world"))
+
+	if err := gateway.DeleteFile(context.Background(), "alice", fileID); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(store.records) != 0 || len(store.objects) != 0 {
+		t.Errorf("record and object should both be gone: %v, %v", store.records, store.objects)
+	}
+
+	err := gateway.DeleteFile(context.Background(), "alice", fileID)
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("deleting twice should report ErrFileNotFound, got: %v", err)
+	}
+}
+
+func TestCloudStorageGateway_DeleteFile_StorageFailureLeavesTombstone(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store)
+	fileID := uploadTestFile(t, gateway, "alice", []byte("hello world"))
+	store.deleteObjectErr = NewStorageError("delete", "my-bucket", fileID, ErrStorageUnavailable, AsTemporary())
+
+	err := gateway.DeleteFile(context.Background(), "alice", fileID)
+
+	if !errors.Is(err, ErrStorageUnavailable) || !IsTemporary(err) {
+		t.Errorf("expected the temporary storage error, got: %v", err)
+	}
+	if status := store.records[fileID].Status; status != StatusDeleted {
+		t.Errorf("the record should be marked deleted before the object goes, got %q", status)
+	}
+	if _, err := downloadAll(gateway, fileID, nil); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("a file being deleted must not be served, got: %v", err)
+	}
+
+	store.deleteObjectErr = nil
+	if err := gateway.DeleteFile(context.Background(), "alice", fileID); err != nil {
+		t.Fatalf("retry should finish the deletion, got: %v", err)
+	}
+	if len(store.records) != 0 || len(store.objects) != 0 {
+		t.Errorf("record and object should both be gone: %v, %v", store.records, store.objects)
+	}
+}
+
+func TestCloudStorageGateway_DeleteFile_FreesQuota(t *testing.T) {
+	store := newMemFileStore()
+	quota := NewInMemoryQuotaService(map[string]int64{"my-bucket": 100})
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store, WithQuotaService(quota))
+	fileID := uploadTestFile(t, gateway, "alice", []byte("hello world"))
+	uploadTestFile(t, gateway, "alice", []byte("kept"))
+	store.deleteRecordErr = NewMetadataError("delete", fileID, ErrDatabaseDeadlock, AsTemporary())
+
+	_ = gateway.DeleteFile(context.Background(), "alice", fileID)
+	store.deleteRecordErr = nil
+	if err := gateway.DeleteFile(context.Background(), "alice", fileID); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if got := quota.Usage("my-bucket"); got != int64(len("kept")) {
+		t.Errorf("the deleted file's bytes should be freed exactly once, usage is %d", got)
+	}
+}
+
+func TestCloudStorageGateway_DeleteFile_RetryFinishesTombstone(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store)
+	fileID := uploadTestFile(t, gateway, "alice", []byte("hello world"))
+	store.deleteRecordErr = NewMetadataError("delete", fileID, ErrDatabaseDeadlock, AsTemporary())
+
+	if err := gateway.DeleteFile(context.Background(), "alice", fileID); !errors.Is(err, ErrDatabaseDeadlock) {
+		t.Fatalf("expected the metadata failure, got: %v", err)
+	}
+	if status := store.records[fileID].Status; status != StatusDeleted {
+		t.Errorf("record should remain as a deleted tombstone, got %q", status)
+	}
+
+	store.deleteRecordErr = nil
+	if err := gateway.DeleteFile(context.Background(), "alice", fileID); err != nil {
+		t.Fatalf("retry should finish the deletion, got: %v", err)
+	}
+	if len(store.records) != 0 {
+		t.Errorf("record should be gone after the retry: %v", store.records)
+	}
+}
+
+// ============================================================================
+// List Tests
+// ============================================================================
+
+func TestCloudStorageGateway_ListFiles_Paginates(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store)
+	for range 5 {
+		uploadTestFile(t, gateway, "alice", []byte("a"))
+	}
+	uploadTestFile(t, gateway, "bob", []byte("b"))
+
+	var ids []string
+	page := PageRequest{Size: 2}
+	for {
+		result, err := gateway.ListFiles(context.Background(), "alice", page)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		for _, rec := range result.Records {
+			ids = append(ids, rec.ID)
+		}
+		if result.NextToken == "" {
+			break
+		}
+		page.Token = result.NextToken
+	}
+
+	if len(ids) != 5 {
+		t.Errorf("expected alice's 5 files, got %v", ids)
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("records should be ordered by ID: %v", ids)
+	}
+}
@@ -0,0 +1,243 @@
+// Package httpgateway serves a CloudStorageGateway over HTTP
+//
+// Two routes accept uploads, both authenticated with an
+// "Authorization: Bearer <token>" header:
+//
+//	PUT  /buckets/{bucket}/files/{name}   raw request body
+//	POST /buckets/{bucket}/files          multipart/form-data with a "file" part
+//
+// Files are always stored under their file ID: a ?key= parameter or "key"
+// field is refused with 400, since object keys are not chosen by HTTP clients.
+// Successful uploads answer 201 with a JSON body naming the file ID and object
+// key. Gateway failures are written with errstatus.WriteProblem, so a rejected
+// token is a 401, an exhausted quota a 507, a backend timeout a 504 and a
+// temporary failure a 503 with Retry-After. Bodies over the configured limit
+// are refused with 413 before the gateway sees them.
+package httpgateway
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	propagator "goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator"
+	"goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator/errstatus"
+)
+
+// DefaultMaxBodySize is the largest request body accepted unless WithMaxBodySize says otherwise
+const DefaultMaxBodySize = 32 << 20
+
+// ============================================================================
+// Handler
+// ============================================================================
+
+// Handler is an http.Handler that uploads request bodies through a gateway
+type Handler struct {
+	gateway     *propagator.CloudStorageGateway
+	logger      *slog.Logger
+	maxBodySize int64
+	mux         *http.ServeMux
+}
+
+// Option configures a Handler
+type Option func(*Handler)
+
+// WithMaxBodySize limits request bodies, multipart framing included, to n bytes
+func WithMaxBodySize(n int64) Option {
+	return func(h *Handler) {
+		h.maxBodySize = n
+	}
+}
+
+// WithLogger sets the logger that reports server-side failures; slog.Default() is used otherwise
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+// New creates a Handler serving uploads into gateway
+func New(gateway *propagator.CloudStorageGateway, opts ...Option) *Handler {
+	h := &Handler{
+		gateway:     gateway,
+		logger:      slog.Default(),
+		maxBodySize: DefaultMaxBodySize,
+		mux:         http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.maxBodySize <= 0 {
+		h.maxBodySize = DefaultMaxBodySize
+	}
+	h.mux.HandleFunc("PUT /buckets/{bucket}/files/{name}", h.putFile)
+	h.mux.HandleFunc("POST /buckets/{bucket}/files", h.postFile)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// uploadResponse is the body of a successful upload
+type uploadResponse struct {
+	FileID   string `json:"file_id"`
+	Key      string `json:"key"`
+	FileName string `json:"file_name"`
+	Size     int    `json:"size"`
+}
+
+// putFile uploads the raw request body under the name in the path
+func (h *Handler) putFile(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		writeUnauthorized(w)
+		return
+	}
+	if r.URL.Query().Has("key") {
+		writeKeyRefused(w)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodySize))
+	if err != nil {
+		h.writeBodyError(w, r, err)
+		return
+	}
+	h.upload(w, r, propagator.FileUploadRequest{
+		Token:    token,
+		FileName: r.PathValue("name"),
+		Bucket:   r.PathValue("bucket"),
+		Data:     data,
+	})
+}
+
+// postFile uploads the "file" part of a multipart/form-data body
+// Parts are read in order straight from the body, so nothing spills to disk
+func (h *Handler) postFile(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		writeUnauthorized(w)
+		return
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "multipart/form-data" {
+		writeProblem(w, http.StatusUnsupportedMediaType, "The request body must be multipart/form-data.")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBodySize)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeProblem(w, http.StatusBadRequest, "The multipart body is malformed.")
+		return
+	}
+
+	req := propagator.FileUploadRequest{Token: token, Bucket: r.PathValue("bucket")}
+	found := false
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			h.writeBodyError(w, r, err)
+			return
+		}
+		switch part.FormName() {
+		case "file":
+			req.FileName = part.FileName()
+			req.Data, err = io.ReadAll(part)
+			found = true
+		case "key":
+			part.Close()
+			writeKeyRefused(w)
+			return
+		}
+		part.Close()
+		if err != nil {
+			h.writeBodyError(w, r, err)
+			return
+		}
+	}
+	if !found {
+		writeProblem(w, http.StatusBadRequest, `The multipart body has no "file" part.`)
+		return
+	}
+	h.upload(w, r, req)
+}
+
+// upload hands req to the gateway and writes the outcome
+func (h *Handler) upload(w http.ResponseWriter, r *http.Request, req propagator.FileUploadRequest) {
+	res, err := h.gateway.UploadFileResult(r.Context(), req)
+	if err != nil {
+		st := errstatus.FromError(err)
+		if st.HTTPStatus == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="uploads", error="invalid_token"`)
+		}
+		if st.HTTPStatus >= http.StatusInternalServerError {
+			h.logger.LogAttrs(r.Context(), slog.LevelError, "http upload failed",
+				slog.String("bucket", req.Bucket),
+				slog.Int("status", st.HTTPStatus),
+				slog.Any("error", err),
+			)
+		}
+		errstatus.WriteProblem(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(uploadResponse{FileID: res.FileID, Key: res.Key, FileName: res.FileName, Size: len(req.Data)})
+}
+
+// writeBodyError reports a failure to read the request body
+func (h *Handler) writeBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeProblem(w, http.StatusRequestEntityTooLarge, "The request body exceeds the size limit.")
+	case r.Context().Err() != nil:
+		errstatus.WriteProblem(w, r.Context().Err())
+	default:
+		writeProblem(w, http.StatusBadRequest, "The request body could not be read.")
+	}
+}
+
+// ============================================================================
+// Helpers
+// ============================================================================
+
+// bearerToken extracts the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// writeUnauthorized answers a request that carried no bearer token
+func writeUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="uploads"`)
+	writeProblem(w, http.StatusUnauthorized, "A bearer token is required.")
+}
+
+// writeKeyRefused answers a request that tried to choose its object key
+func writeKeyRefused(w http.ResponseWriter) {
+	writeProblem(w, http.StatusBadRequest, "Object keys cannot be chosen over HTTP.")
+}
+
+// writeProblem writes a problem+json response for failures found before the gateway is called
+func writeProblem(w http.ResponseWriter, status int, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errstatus.Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	})
+}
@@ -0,0 +1,275 @@
+package httpgateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	propagator "goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator"
+	"goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator/errstatus"
+	"goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator/faultinject"
+)
+
+// newTestServer serves a gateway over in-memory services that accept the token "tok"
+func newTestServer(t *testing.T, inj *faultinject.Injector, gatewayOpts []propagator.Option, opts ...Option) (*httptest.Server, *faultinject.Storage) {
+	t.Helper()
+	storage := faultinject.NewStorage()
+	if inj != nil {
+		gatewayOpts = append(gatewayOpts, propagator.WithInterceptors(inj.Interceptor()))
+	}
+	gateway := propagator.NewCloudStorageGateway(faultinject.NewAuth(map[string]string{"tok": "alice"}), faultinject.NewMetadata(), storage, gatewayOpts...)
+	opts = append([]Option{WithLogger(slog.New(slog.DiscardHandler))}, opts...)
+	srv := httptest.NewServer(New(gateway, opts...))
+	t.Cleanup(srv.Close)
+	return srv, storage
+}
+
+// put sends body to PUT /buckets/{bucket}/files/{name}
+func put(t *testing.T, srv *httptest.Server, path, token string, body []byte) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, srv.URL+path, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// decodeProblem reads a problem+json response body
+func decodeProblem(t *testing.T, resp *http.Response) errstatus.Problem {
+	t.Helper()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("Content-Type = %q, want application/problem+json", ct)
+	}
+	var p errstatus.Problem
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// ============================================================================
+// Upload Tests
+// ============================================================================
+
+func TestHandler_PutUploadsBody(t *testing.T) {
+	srv, storage := newTestServer(t, nil, nil)
+
+	resp := put(t, srv, "/buckets/docs/files/report.txt", "tok", []byte("hello"))
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+	var body uploadResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.FileID == "" || body.Key != body.FileID || body.FileName != "report.txt" || body.Size != 5 {
+		t.Errorf("unexpected response: %+v", body)
+	}
+	if data, ok := storage.Object("docs", body.FileID); !ok || string(data) != "hello" {
+		t.Errorf("expected the body stored under the file ID, got %q", data)
+	}
+}
+
+func TestHandler_PostUploadsMultipartFile(t *testing.T) {
+	srv, storage := newTestServer(t, nil, nil)
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("comment", "ignored")
+	fw, _ := mw.CreateFormFile("file", "../photo.png")
+	_, _ = fw.Write([]byte("png bytes"))
+	_ = mw.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/buckets/images/files", &buf)
+	req.Header.Set("Authorization", "Bearer tok")
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", resp.StatusCode)
+	}
+	var body uploadResponse
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	if body.FileName != "photo.png" || body.Key != body.FileID {
+		t.Errorf("unexpected response: %+v", body)
+	}
+	if data, ok := storage.Object("images", body.Key); !ok || string(data) != "png bytes" {
+		t.Errorf("expected the file part stored, got %q", data)
+	}
+}
+
+func TestHandler_PostRejectsMissingFilePart(t *testing.T) {
+	srv, _ := newTestServer(t, nil, nil)
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("comment", "no file")
+	_ = mw.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/buckets/images/files", &buf)
+	req.Header.Set("Authorization", "Bearer tok")
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if p := decodeProblem(t, resp); resp.StatusCode != http.StatusBadRequest || p.Status != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d %+v", resp.StatusCode, p)
+	}
+}
+
+func TestHandler_RefusesClientKeys(t *testing.T) {
+	srv, storage := newTestServer(t, nil, nil)
+
+	putResp := put(t, srv, "/buckets/docs/files/report.txt?key=file-000001", "tok", []byte("hello"))
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("key", "file-000001")
+	fw, _ := mw.CreateFormFile("file", "report.txt")
+	_, _ = fw.Write([]byte("hello"))
+	_ = mw.Close()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/buckets/docs/files", &buf)
+	req.Header.Set("Authorization", "Bearer tok")
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	postResp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer postResp.Body.Close()
+
+	for name, resp := range map[string]*http.Response{"put": putResp, "post": postResp} {
+		if p := decodeProblem(t, resp); resp.StatusCode != http.StatusBadRequest || p.Status != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d %+v", name, resp.StatusCode, p)
+		}
+	}
+	if _, ok := storage.Object("docs", "file-000001"); ok {
+		t.Error("nothing should be stored for a refused key")
+	}
+}
+
+func TestHandler_PostRequiresMultipart(t *testing.T) {
+	srv, _ := newTestServer(t, nil, nil)
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/buckets/images/files", strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Bearer tok")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if p := decodeProblem(t, resp); p.Status != http.StatusUnsupportedMediaType || resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415, got %d %+v", resp.StatusCode, p)
+	}
+}
+
+// ============================================================================
+// Error Mapping Tests
+// ============================================================================
+
+func TestHandler_ErrorStatuses(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       string
+		rules       []faultinject.Rule
+		gatewayOpts []propagator.Option
+		opts        []Option
+		body        []byte
+		status      int
+		retryAfter  bool
+	}{
+		{
+			name:   "missing token",
+			body:   []byte("hello"),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:   "rejected token",
+			token:  "wrong",
+			body:   []byte("hello"),
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:        "quota exceeded",
+			token:       "tok",
+			gatewayOpts: []propagator.Option{propagator.WithQuotaService(propagator.NewInMemoryQuotaService(map[string]int64{"docs": 3}))},
+			body:        []byte("hello"),
+			status:      http.StatusInsufficientStorage,
+		},
+		{
+			name:   "body too large",
+			token:  "tok",
+			opts:   []Option{WithMaxBodySize(4)},
+			body:   []byte("hello"),
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "storage timeout",
+			token:      "tok",
+			rules:      []faultinject.Rule{{Service: propagator.ServiceStorage, Method: "UploadFile", Fault: faultinject.FaultTimeout}},
+			body:       []byte("hello"),
+			status:     http.StatusGatewayTimeout,
+			retryAfter: true,
+		},
+		{
+			name:       "temporary metadata failure",
+			token:      "tok",
+			rules:      []faultinject.Rule{{Service: propagator.ServiceMetadata, Method: "CreateFileRecord", Fault: faultinject.FaultTemporary}},
+			body:       []byte("hello"),
+			status:     http.StatusServiceUnavailable,
+			retryAfter: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newTestServer(t, faultinject.New(tt.rules...), tt.gatewayOpts, tt.opts...)
+
+			resp := put(t, srv, "/buckets/docs/files/a.txt", tt.token, tt.body)
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if p := decodeProblem(t, resp); p.Status != tt.status {
+				t.Errorf("problem status = %d, want %d", p.Status, tt.status)
+			}
+			if got := resp.Header.Get("Retry-After") != ""; got != tt.retryAfter {
+				t.Errorf("Retry-After set = %v, want %v", got, tt.retryAfter)
+			}
+			if tt.status == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Error("401 responses must carry WWW-Authenticate")
+			}
+		})
+	}
+}
+
+func TestHandler_ProblemHidesErrorDetails(t *testing.T) {
+	srv, _ := newTestServer(t, faultinject.New(faultinject.Rule{Service: propagator.ServiceStorage, Method: "UploadFile", Fault: faultinject.FaultTemporary}), nil)
+
+	resp := put(t, srv, "/buckets/docs/files/a.txt", "tok", []byte("hello"))
+
+	body, _ := io.ReadAll(resp.Body)
+	if strings.Contains(string(body), "tok") || strings.Contains(string(body), "storage error") {
+		t.Errorf("response leaks internal details: %s", body)
+	}
+}
@@ -0,0 +1,356 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// Service Interceptors
+// ============================================================================
+
+// Service names reported in Call.Service
+const (
+	ServiceAuth     = "auth"
+	ServiceMetadata = "metadata"
+	ServiceStorage  = "storage"
+)
+
+// Call describes one intercepted service method invocation
+// Only identifiers safe for logging are included; tokens and content never are
+type Call struct {
+	Service string // ServiceAuth, ServiceMetadata or ServiceStorage
+	Method  string // Interface method name (e.g., "UploadFile")
+	FileID  string // Set for metadata calls addressing a file
+	Bucket  string // Set for storage calls
+	Key     string // Set for storage calls
+}
+
+// String returns "service.Method"
+func (c Call) String() string {
+	return c.Service + "." + c.Method
+}
+
+// Invoker runs the intercepted call, or the next interceptor in the chain
+type Invoker func(ctx context.Context) error
+
+// Interceptor runs around a service call
+// It must call invoke at most once and should return its error, wrapped or
+// not, so that errors.Is/As, IsTimeout and IsTemporary keep working
+type Interceptor func(ctx context.Context, call Call, invoke Invoker) error
+
+// Chain composes interceptors into one; the first is the outermost
+func Chain(interceptors ...Interceptor) Interceptor {
+	return func(ctx context.Context, call Call, invoke Invoker) error {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			next, ic := invoke, interceptors[i]
+			invoke = func(ctx context.Context) error { return ic(ctx, call, next) }
+		}
+		return invoke(ctx)
+	}
+}
+
+// WithInterceptors wraps the gateway's auth, metadata and storage services
+// with the interceptors; the first is the outermost
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(g *CloudStorageGateway) {
+		g.auth = InterceptAuth(g.auth, interceptors...)
+		g.metadata = InterceptMetadata(g.metadata, interceptors...)
+		g.storage = InterceptStorage(g.storage, interceptors...)
+	}
+}
+
+// InterceptAuth wraps every AuthService method with the interceptors
+// If next is an ExpiringAuthService the result is one too
+func InterceptAuth(next AuthService, interceptors ...Interceptor) AuthService {
+	a := &interceptedAuth{next: next, intercept: Chain(interceptors...)}
+	if exp, ok := next.(ExpiringAuthService); ok {
+		return &interceptedExpiringAuth{interceptedAuth: a, next: exp}
+	}
+	return a
+}
+
+type interceptedAuth struct {
+	next      AuthService
+	intercept Interceptor
+}
+
+func (s *interceptedAuth) ValidateToken(ctx context.Context, token string) (userID string, err error) {
+	err = s.intercept(ctx, Call{Service: ServiceAuth, Method: "ValidateToken"}, func(ctx context.Context) error {
+		userID, err = s.next.ValidateToken(ctx, token)
+		return err
+	})
+	return userID, err
+}
+
+type interceptedExpiringAuth struct {
+	*interceptedAuth
+	next ExpiringAuthService
+}
+
+func (s *interceptedExpiringAuth) ValidateTokenExpiry(ctx context.Context, token string) (userID string, expiresAt time.Time, err error) {
+	err = s.intercept(ctx, Call{Service: ServiceAuth, Method: "ValidateTokenExpiry"}, func(ctx context.Context) error {
+		userID, expiresAt, err = s.next.ValidateTokenExpiry(ctx, token)
+		return err
+	})
+	return userID, expiresAt, err
+}
+
+// InterceptMetadata wraps every MetadataService method with the interceptors
+func InterceptMetadata(next MetadataService, interceptors ...Interceptor) MetadataService {
+	return &interceptedMetadata{next: next, intercept: Chain(interceptors...)}
+}
+
+type interceptedMetadata struct {
+	next      MetadataService
+	intercept Interceptor
+}
+
+func (s *interceptedMetadata) CreateFileRecord(ctx context.Context, rec FileRecord) (fileID string, err error) {
+	err = s.intercept(ctx, Call{Service: ServiceMetadata, Method: "CreateFileRecord"}, func(ctx context.Context) error {
+		fileID, err = s.next.CreateFileRecord(ctx, rec)
+		return err
+	})
+	return fileID, err
+}
+
+func (s *interceptedMetadata) UpdateFileStatus(ctx context.Context, fileID string, status FileStatus) error {
+	return s.intercept(ctx, Call{Service: ServiceMetadata, Method: "UpdateFileStatus", FileID: fileID}, func(ctx context.Context) error {
+		return s.next.UpdateFileStatus(ctx, fileID, status)
+	})
+}
+
+func (s *interceptedMetadata) GetFileRecord(ctx context.Context, fileID string) (rec FileRecord, err error) {
+	err = s.intercept(ctx, Call{Service: ServiceMetadata, Method: "GetFileRecord", FileID: fileID}, func(ctx context.Context) error {
+		rec, err = s.next.GetFileRecord(ctx, fileID)
+		return err
+	})
+	return rec, err
+}
+
+func (s *interceptedMetadata) DeleteFileRecord(ctx context.Context, fileID string) error {
+	return s.intercept(ctx, Call{Service: ServiceMetadata, Method: "DeleteFileRecord", FileID: fileID}, func(ctx context.Context) error {
+		return s.next.DeleteFileRecord(ctx, fileID)
+	})
+}
+
+func (s *interceptedMetadata) ListFileRecords(ctx context.Context, userID string, page PageRequest) (result FileRecordPage, err error) {
+	err = s.intercept(ctx, Call{Service: ServiceMetadata, Method: "ListFileRecords"}, func(ctx context.Context) error {
+		result, err = s.next.ListFileRecords(ctx, userID, page)
+		return err
+	})
+	return result, err
+}
+
+func (s *interceptedMetadata) ListRecordsByStatus(ctx context.Context, status FileStatus, page PageRequest) (result FileRecordPage, err error) {
+	err = s.intercept(ctx, Call{Service: ServiceMetadata, Method: "ListRecordsByStatus"}, func(ctx context.Context) error {
+		result, err = s.next.ListRecordsByStatus(ctx, status, page)
+		return err
+	})
+	return result, err
+}
+
+// InterceptStorage wraps every StorageService method with the interceptors
+// For DownloadFile only opening the stream is intercepted, not reading it
+func InterceptStorage(next StorageService, interceptors ...Interceptor) StorageService {
+	return &interceptedStorage{next: next, intercept: Chain(interceptors...)}
+}
+
+type interceptedStorage struct {
+	next      StorageService
+	intercept Interceptor
+}
+
+func (s *interceptedStorage) UploadFile(ctx context.Context, bucket, key string, data []byte) error {
+	return s.intercept(ctx, Call{Service: ServiceStorage, Method: "UploadFile", Bucket: bucket, Key: key}, func(ctx context.Context) error {
+		return s.next.UploadFile(ctx, bucket, key, data)
+	})
+}
+
+func (s *interceptedStorage) ObjectChecksum(ctx context.Context, bucket, key string, alg ChecksumAlgorithm) (sum Checksum, err error) {
+	err = s.intercept(ctx, Call{Service: ServiceStorage, Method: "ObjectChecksum", Bucket: bucket, Key: key}, func(ctx context.Context) error {
+		sum, err = s.next.ObjectChecksum(ctx, bucket, key, alg)
+		return err
+	})
+	return sum, err
+}
+
+func (s *interceptedStorage) DownloadFile(ctx context.Context, bucket, key string, rng *ByteRange) (body io.ReadCloser, err error) {
+	err = s.intercept(ctx, Call{Service: ServiceStorage, Method: "DownloadFile", Bucket: bucket, Key: key}, func(ctx context.Context) error {
+		body, err = s.next.DownloadFile(ctx, bucket, key, rng)
+		return err
+	})
+	return body, err
+}
+
+func (s *interceptedStorage) StatFile(ctx context.Context, bucket, key string) (info ObjectInfo, err error) {
+	err = s.intercept(ctx, Call{Service: ServiceStorage, Method: "StatFile", Bucket: bucket, Key: key}, func(ctx context.Context) error {
+		info, err = s.next.StatFile(ctx, bucket, key)
+		return err
+	})
+	return info, err
+}
+
+func (s *interceptedStorage) DeleteFile(ctx context.Context, bucket, key string) error {
+	return s.intercept(ctx, Call{Service: ServiceStorage, Method: "DeleteFile", Bucket: bucket, Key: key}, func(ctx context.Context) error {
+		return s.next.DeleteFile(ctx, bucket, key)
+	})
+}
+
+// ============================================================================
+// Built-in Interceptors
+// ============================================================================
+
+// LoggingInterceptor logs every call with its duration
+// Successful calls are logged at Debug and failures at Warn when temporary
+// and at Error otherwise. Errors are logged through their LogValue, so
+// credentials stay redacted
+func LoggingInterceptor(logger *slog.Logger) Interceptor {
+	return func(ctx context.Context, call Call, invoke Invoker) error {
+		start := time.Now()
+		err := invoke(ctx)
+
+		attrs := []slog.Attr{
+			slog.String("service", call.Service),
+			slog.String("method", call.Method),
+			slog.Duration("duration", time.Since(start)),
+		}
+		for _, f := range []struct{ key, value string }{{"file_id", call.FileID}, {"bucket", call.Bucket}, {"key", call.Key}} {
+			if f.value != "" {
+				attrs = append(attrs, slog.String(f.key, f.value))
+			}
+		}
+
+		level := slog.LevelDebug
+		switch {
+		case err == nil:
+		case IsTemporary(err):
+			level = slog.LevelWarn
+		default:
+			level = slog.LevelError
+		}
+		if err != nil {
+			attrs = append(attrs, slog.Any("error", err))
+		}
+		logger.LogAttrs(ctx, level, "service call", attrs...)
+		return err
+	}
+}
+
+// TimeoutInterceptor bounds every call to d
+// When the call fails because d elapsed (rather than the caller's own
+// deadline), the error is wrapped so that IsTimeout and IsTemporary report
+// true and errors.Is(err, context.DeadlineExceeded) holds, even if the
+// backend returned an unrelated error once its context expired
+// StorageService.DownloadFile is passed through unbounded: its stream is read
+// after the call returns, and cancelling the call's context would break
+// backends that tie the stream to it. Bound downloads with the caller's context
+func TimeoutInterceptor(d time.Duration) Interceptor {
+	return func(ctx context.Context, call Call, invoke Invoker) error {
+		if call.Service == ServiceStorage && call.Method == "DownloadFile" {
+			return invoke(ctx)
+		}
+		callCtx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+
+		err := invoke(callCtx)
+		if err == nil || ctx.Err() != nil || !errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+			return err
+		}
+		return &callTimeoutError{call: call, timeout: d, err: err}
+	}
+}
+
+// callTimeoutError reports that an intercepted call exceeded its per-call timeout
+type callTimeoutError struct {
+	call    Call
+	timeout time.Duration
+	err     error
+}
+
+func (e *callTimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %v: %v", e.call, e.timeout, e.err)
+}
+
+func (e *callTimeoutError) Unwrap() error { return e.err }
+
+// Is matches context.DeadlineExceeded whatever the backend returned
+func (e *callTimeoutError) Is(target error) bool { return target == context.DeadlineExceeded }
+
+func (e *callTimeoutError) Timeout() bool { return true }
+
+// Temporary reports true: another attempt may finish in time
+func (e *callTimeoutError) Temporary() bool { return true }
+
+// MetricsRecorder receives one observation per intercepted call
+type MetricsRecorder interface {
+	ObserveCall(call Call, duration time.Duration, err error)
+}
+
+// MetricsInterceptor reports every call to rec
+func MetricsInterceptor(rec MetricsRecorder) Interceptor {
+	return func(ctx context.Context, call Call, invoke Invoker) error {
+		start := time.Now()
+		err := invoke(ctx)
+		rec.ObserveCall(call, time.Since(start), err)
+		return err
+	}
+}
+
+// CallStats aggregates the calls of one service method
+type CallStats struct {
+	Calls     int
+	Errors    int
+	Timeouts  int
+	Temporary int
+	Duration  time.Duration // Total time spent in calls
+}
+
+// CallMetrics is an in-memory MetricsRecorder keyed by "service.Method"
+// It is safe for concurrent use
+type CallMetrics struct {
+	mu    sync.Mutex
+	stats map[string]CallStats
+}
+
+// NewCallMetrics creates an empty CallMetrics
+func NewCallMetrics() *CallMetrics {
+	return &CallMetrics{stats: make(map[string]CallStats)}
+}
+
+// ObserveCall implements MetricsRecorder
+func (m *CallMetrics) ObserveCall(call Call, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.stats[call.String()]
+	s.Calls++
+	s.Duration += duration
+	if err != nil {
+		s.Errors++
+		if IsTimeout(err) {
+			s.Timeouts++
+		}
+		if IsTemporary(err) {
+			s.Temporary++
+		}
+	}
+	m.stats[call.String()] = s
+}
+
+// Snapshot returns a copy of the current stats
+func (m *CallMetrics) Snapshot() map[string]CallStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make(map[string]CallStats, len(m.stats))
+	for k, v := range m.stats {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,198 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stallingStorageService blocks uploads until ctx ends, then fails with a plain error
+type stallingStorageService struct {
+	mockStorageService
+}
+
+func (m *stallingStorageService) UploadFile(ctx context.Context, bucket, key string, data []byte) error {
+	<-ctx.Done()
+	return errors.New("connection reset")
+}
+
+// ============================================================================
+// Interceptor Chain Tests
+// ============================================================================
+
+func TestChain_Order(t *testing.T) {
+	var trace []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, call Call, invoke Invoker) error {
+			trace = append(trace, name+">")
+			err := invoke(ctx)
+			trace = append(trace, "<"+name)
+			return err
+		}
+	}
+
+	storage := InterceptStorage(&mockStorageService{}, record("a"), record("b"))
+	if err := storage.UploadFile(context.Background(), "b", "k", []byte("x")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := strings.Join(trace, " "); got != "a> b> <b <a" {
+		t.Errorf("trace = %q, want the first interceptor outermost", got)
+	}
+}
+
+func TestInterceptAuth_PreservesExpiry(t *testing.T) {
+	auth := InterceptAuth(&expiringAuthService{}, MetricsInterceptor(NewCallMetrics()))
+	if _, ok := auth.(ExpiringAuthService); !ok {
+		t.Error("intercepting an ExpiringAuthService should keep ValidateTokenExpiry")
+	}
+	if _, ok := InterceptAuth(&mockAuthService{}).(ExpiringAuthService); ok {
+		t.Error("a plain AuthService must not gain ValidateTokenExpiry")
+	}
+}
+
+// ============================================================================
+// Built-in Interceptor Tests
+// ============================================================================
+
+func TestTimeoutInterceptor_KeepsTimeoutContract(t *testing.T) {
+	storage := InterceptStorage(&stallingStorageService{}, TimeoutInterceptor(10*time.Millisecond))
+
+	err := storage.UploadFile(context.Background(), "my-bucket", "k", []byte("x"))
+
+	if !IsTimeout(err) || !IsTemporary(err) {
+		t.Errorf("a per-call timeout should be a temporary timeout, got: %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("errors.Is(err, context.DeadlineExceeded) should hold")
+	}
+	if !strings.Contains(err.Error(), "storage.UploadFile timed out") {
+		t.Errorf("missing call context: %v", err)
+	}
+}
+
+func TestTimeoutInterceptor_PreservesTypedErrors(t *testing.T) {
+	storageErr := NewStorageError("upload", "my-bucket", "k", ErrStorageUnavailable)
+	storage := InterceptStorage(&mockStorageService{err: storageErr}, TimeoutInterceptor(time.Second))
+
+	err := storage.UploadFile(context.Background(), "my-bucket", "k", []byte("x"))
+
+	if err != storageErr {
+		t.Errorf("errors unrelated to the timeout should pass through unchanged, got: %v", err)
+	}
+}
+
+func TestTimeoutInterceptor_CallerCancellationPassesThrough(t *testing.T) {
+	storage := InterceptStorage(&stallingStorageService{}, TimeoutInterceptor(time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := storage.UploadFile(ctx, "my-bucket", "k", []byte("x"))
+
+	if IsTimeout(err) {
+		t.Errorf("the caller's own deadline is not a per-call timeout, got: %v", err)
+	}
+}
+
+// contextBoundStorageService streams downloads that fail once the download's context is done
+type contextBoundStorageService struct {
+	mockStorageService
+}
+
+func (m *contextBoundStorageService) DownloadFile(ctx context.Context, bucket, key string, rng *ByteRange) (io.ReadCloser, error) {
+	return io.NopCloser(&contextReader{ctx: ctx, r: strings.NewReader("hello")}), nil
+}
+
+// contextReader fails reads after ctx is done, like a network stream
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+func TestTimeoutInterceptor_DownloadStreamOutlivesCall(t *testing.T) {
+	storage := InterceptStorage(&contextBoundStorageService{}, TimeoutInterceptor(time.Minute))
+
+	body, err := storage.DownloadFile(context.Background(), "my-bucket", "k", nil)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+
+	if err != nil || string(data) != "hello" {
+		t.Errorf("the stream must stay readable after DownloadFile returns, got %q, %v", data, err)
+	}
+}
+
+func TestLoggingInterceptor_LevelsAndRedaction(t *testing.T) {
+	var buf strings.Builder
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	secret := "sk-super-secret-api-key-12345"
+	auth := InterceptAuth(&mockAuthService{err: NewAuthError("validate_token", "user123", secret, ErrInvalidToken)}, LoggingInterceptor(logger))
+	storage := InterceptStorage(&mockStorageService{err: NewStorageError("upload", "my-bucket", "k", ErrStorageUnavailable, AsTemporary())}, LoggingInterceptor(logger))
+	metadata := InterceptMetadata(&mockMetadataService{}, LoggingInterceptor(logger))
+
+	_, _ = auth.ValidateToken(context.Background(), "token")
+	_ = storage.UploadFile(context.Background(), "my-bucket", "k", nil)
+	_ = metadata.UpdateFileStatus(context.Background(), "file456", StatusCompleted)
+
+	out := buf.String()
+	for _, want := range []string{
+		"level=ERROR msg=\"service call\" service=auth method=ValidateToken",
+		"level=WARN msg=\"service call\" service=storage method=UploadFile",
+		"bucket=my-bucket key=k",
+		"level=DEBUG msg=\"service call\" service=metadata method=UpdateFileStatus",
+		"file_id=file456",
+		"error.code=AUTH_INVALID_TOKEN",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, secret) {
+		t.Errorf("log output leaked the API key:\n%s", out)
+	}
+}
+
+func TestMetricsInterceptor_ThroughGateway(t *testing.T) {
+	metrics := NewCallMetrics()
+	gateway := NewCloudStorageGateway(
+		&mockAuthService{userID: "user123"},
+		&mockMetadataService{fileID: "file456"},
+		&stallingStorageService{},
+		WithInterceptors(MetricsInterceptor(metrics), TimeoutInterceptor(10*time.Millisecond)),
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     []byte("hello world"),
+	})
+
+	if !IsTimeout(err) {
+		t.Fatalf("expected a timeout through the gateway, got: %v", err)
+	}
+	stats := metrics.Snapshot()
+	if s := stats["storage.UploadFile"]; s.Calls != 1 || s.Errors != 1 || s.Timeouts != 1 || s.Temporary != 1 {
+		t.Errorf("unexpected storage stats: %+v", s)
+	}
+	if s := stats["auth.ValidateToken"]; s.Calls != 1 || s.Errors != 0 {
+		t.Errorf("unexpected auth stats: %+v", s)
+	}
+	if s := stats["metadata.UpdateFileStatus"]; s.Calls != 2 {
+		t.Errorf("uploading and failed should be recorded as status updates: %+v", s)
+	}
+}
@@ -0,0 +1,437 @@
+// Package localstore is a StorageService backed by the local filesystem
+//
+// Objects are stored as files under a root directory, one subdirectory per
+// bucket; keys may contain "/" to form nested paths. All access goes through
+// an os.Root, so no bucket or key can reach outside the root, and names that
+// would try are rejected up front with ErrInvalidObjectName. Uploads are
+// written to a temporary file and renamed into place, so readers never see a
+// partial object. Per-bucket quotas are enforced with StorageQuotaError.
+package localstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	propagator "goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator"
+)
+
+// tmpDir holds in-progress uploads; bucket names cannot start with "." so it never collides
+const tmpDir = ".tmp"
+
+// SyncMode selects how much an upload is flushed to disk before it is reported done
+type SyncMode int
+
+const (
+	// SyncAll fsyncs the file and the directory entry created by the rename
+	// An upload that returned nil survives a crash
+	SyncAll SyncMode = iota
+	// SyncFile fsyncs the file contents but not the directory
+	SyncFile
+	// SyncNone leaves flushing to the OS; fastest, for tests and scratch data
+	SyncNone
+)
+
+// Store is a filesystem StorageService
+// It is safe for concurrent use
+type Store struct {
+	root     *os.Root
+	syncMode SyncMode
+	quotas   map[string]int64
+
+	mu    sync.Mutex
+	usage map[string]int64 // Bytes stored per quota-limited bucket, loaded lazily
+}
+
+// Option configures a Store
+type Option func(*Store)
+
+// WithSyncMode sets how uploads are flushed; the default is SyncAll
+func WithSyncMode(mode SyncMode) Option {
+	return func(s *Store) {
+		s.syncMode = mode
+	}
+}
+
+// WithBucketQuota limits the bytes stored in bucket
+func WithBucketQuota(bucket string, limit int64) Option {
+	return func(s *Store) {
+		s.quotas[bucket] = limit
+	}
+}
+
+// Open creates a Store rooted at dir, creating the directory if needed
+func Open(dir string, opts ...Option) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("localstore: create root: %w", err)
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, fmt.Errorf("localstore: open root: %w", err)
+	}
+	// Leftovers from a crash are never renamed into place; start clean
+	if err := root.RemoveAll(tmpDir); err != nil {
+		root.Close()
+		return nil, fmt.Errorf("localstore: clear temp dir: %w", err)
+	}
+	if err := root.Mkdir(tmpDir, 0o755); err != nil {
+		root.Close()
+		return nil, fmt.Errorf("localstore: create temp dir: %w", err)
+	}
+
+	s := &Store{
+		root:   root,
+		quotas: make(map[string]int64),
+		usage:  make(map[string]int64),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// Close releases the root directory
+func (s *Store) Close() error {
+	return s.root.Close()
+}
+
+// Usage returns the bytes currently stored in bucket
+func (s *Store) Usage(bucket string) (int64, error) {
+	if err := validBucket(bucket); err != nil {
+		return 0, propagator.NewStorageError("usage", bucket, "", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	usage, err := s.loadUsage(bucket)
+	if err != nil {
+		return 0, propagator.NewStorageError("usage", bucket, "", err)
+	}
+	return usage, nil
+}
+
+// UploadFile atomically stores data as bucket/key, replacing any existing object
+// Returns StorageQuotaError if the bucket's quota would be exceeded
+func (s *Store) UploadFile(ctx context.Context, bucket, key string, data []byte) error {
+	name, err := objectPath(bucket, key)
+	if err != nil {
+		return propagator.NewStorageError("upload", bucket, key, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return contextError("upload", bucket, key, err)
+	}
+	if err := s.checkQuota(bucket, name, int64(len(data))); err != nil {
+		return err
+	}
+
+	tmp, err := s.writeTemp(data)
+	if err != nil {
+		return propagator.NewStorageError("upload", bucket, key, err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = s.root.Remove(tmp)
+		}
+	}()
+
+	if err := s.root.MkdirAll(path.Dir(name), 0o755); err != nil {
+		return propagator.NewStorageError("upload", bucket, key, err)
+	}
+	// A cancelled upload must not replace the existing object
+	if err := ctx.Err(); err != nil {
+		return contextError("upload", bucket, key, err)
+	}
+	if err := s.commit(bucket, key, tmp, name, int64(len(data))); err != nil {
+		return err
+	}
+	committed = true
+
+	if s.syncMode == SyncAll {
+		if err := s.syncDir(path.Dir(name)); err != nil {
+			return propagator.NewStorageError("upload", bucket, key, err)
+		}
+	}
+	return nil
+}
+
+// ObjectChecksum streams the stored object through the checksum algorithm
+func (s *Store) ObjectChecksum(ctx context.Context, bucket, key string, alg propagator.ChecksumAlgorithm) (propagator.Checksum, error) {
+	f, _, err := s.open("checksum", bucket, key)
+	if err != nil {
+		return propagator.Checksum{}, err
+	}
+	defer f.Close()
+
+	sum, err := propagator.ComputeChecksumReader(alg, f)
+	if err != nil {
+		return propagator.Checksum{}, propagator.NewStorageError("checksum", bucket, key, err)
+	}
+	return sum, nil
+}
+
+// DownloadFile opens the object for streaming, restricted to rng when it is non-nil
+func (s *Store) DownloadFile(ctx context.Context, bucket, key string, rng *propagator.ByteRange) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError("download", bucket, key, err)
+	}
+	f, size, err := s.open("download", bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := int64(0), size
+	if rng != nil {
+		if start, end, err = rng.Bounds(size); err != nil {
+			f.Close()
+			return nil, propagator.NewStorageError("download", bucket, key, err)
+		}
+	}
+	return sectionReadCloser{io.NewSectionReader(f, start, end-start), f}, nil
+}
+
+// StatFile reports the size of the object
+func (s *Store) StatFile(ctx context.Context, bucket, key string) (propagator.ObjectInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return propagator.ObjectInfo{}, contextError("stat", bucket, key, err)
+	}
+	f, size, err := s.open("stat", bucket, key)
+	if err != nil {
+		return propagator.ObjectInfo{}, err
+	}
+	f.Close()
+	return propagator.ObjectInfo{Size: size}, nil
+}
+
+// DeleteFile removes the object
+func (s *Store) DeleteFile(ctx context.Context, bucket, key string) error {
+	name, err := objectPath(bucket, key)
+	if err != nil {
+		return propagator.NewStorageError("delete", bucket, key, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return contextError("delete", bucket, key, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := s.root.Stat(name)
+	if err == nil && info.IsDir() {
+		err = fs.ErrNotExist
+	}
+	if err == nil {
+		err = s.root.Remove(name)
+	}
+	if err != nil {
+		return propagator.NewStorageError("delete", bucket, key, notFound(err))
+	}
+	if _, tracked := s.usage[bucket]; tracked {
+		s.usage[bucket] -= info.Size()
+	}
+	return nil
+}
+
+// checkQuota rejects an upload early, before anything is written, if storing
+// size bytes at name would exceed the bucket's quota
+func (s *Store) checkQuota(bucket, name string, size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkQuotaLocked(bucket, name, size)
+}
+
+// checkQuotaLocked is checkQuota with s.mu held
+func (s *Store) checkQuotaLocked(bucket, name string, size int64) error {
+	limit, ok := s.quotas[bucket]
+	if !ok {
+		return nil
+	}
+	usage, err := s.loadUsage(bucket)
+	if err != nil {
+		return propagator.NewStorageError("upload", bucket, "", err)
+	}
+	if usage-s.existingSize(name)+size > limit {
+		return propagator.NewStorageQuotaError(bucket, usage, limit)
+	}
+	return nil
+}
+
+// commit renames tmp into place and updates the bucket's usage
+// The quota is checked again under the lock, so concurrent uploads that each
+// passed checkQuota cannot exceed it together
+func (s *Store) commit(bucket, key, tmp, name string, size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkQuotaLocked(bucket, name, size); err != nil {
+		return err
+	}
+	old := s.existingSize(name)
+	if err := s.root.Rename(tmp, name); err != nil {
+		return propagator.NewStorageError("upload", bucket, key, err)
+	}
+	if _, tracked := s.usage[bucket]; tracked {
+		s.usage[bucket] += size - old
+	}
+	return nil
+}
+
+// writeTemp writes data to a new file in the temp directory and returns its name
+func (s *Store) writeTemp(data []byte) (string, error) {
+	var suffix [8]byte
+	rand.Read(suffix[:])
+	name := path.Join(tmpDir, hex.EncodeToString(suffix[:]))
+
+	f, err := s.root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if err == nil && s.syncMode != SyncNone {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = s.root.Remove(name)
+		return "", err
+	}
+	return name, nil
+}
+
+// syncDir fsyncs a directory so a rename into it is durable
+func (s *Store) syncDir(dir string) error {
+	d, err := s.root.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// open opens an existing object and returns its size
+func (s *Store) open(op, bucket, key string) (*os.File, int64, error) {
+	name, err := objectPath(bucket, key)
+	if err != nil {
+		return nil, 0, propagator.NewStorageError(op, bucket, key, err)
+	}
+	f, err := s.root.Open(name)
+	if err != nil {
+		return nil, 0, propagator.NewStorageError(op, bucket, key, notFound(err))
+	}
+	info, err := f.Stat()
+	if err == nil && info.IsDir() {
+		err = propagator.ErrObjectNotFound
+	}
+	if err != nil {
+		f.Close()
+		return nil, 0, propagator.NewStorageError(op, bucket, key, err)
+	}
+	return f, info.Size(), nil
+}
+
+// existingSize returns the size of the object at name, or 0 if there is none
+func (s *Store) existingSize(name string) int64 {
+	info, err := s.root.Stat(name)
+	if err != nil || info.IsDir() {
+		return 0
+	}
+	return info.Size()
+}
+
+// loadUsage returns the bucket's usage, walking the bucket on first use; s.mu must be held
+func (s *Store) loadUsage(bucket string) (int64, error) {
+	if usage, ok := s.usage[bucket]; ok {
+		return usage, nil
+	}
+	var usage int64
+	err := fs.WalkDir(s.root.FS(), bucket, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			usage += info.Size()
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	s.usage[bucket] = usage
+	return usage, nil
+}
+
+// ============================================================================
+// Names
+// ============================================================================
+
+// objectPath validates bucket and key and returns the object's path within the root
+func objectPath(bucket, key string) (string, error) {
+	if err := validBucket(bucket); err != nil {
+		return "", err
+	}
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") {
+		return "", fmt.Errorf("%w: key %q", propagator.ErrInvalidObjectName, key)
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if !validSegment(seg) {
+			return "", fmt.Errorf("%w: key %q", propagator.ErrInvalidObjectName, key)
+		}
+	}
+	return bucket + "/" + key, nil
+}
+
+// validBucket accepts a single path segment that does not start with "."
+func validBucket(bucket string) error {
+	if !validSegment(bucket) || strings.ContainsRune(bucket, '/') || strings.HasPrefix(bucket, ".") {
+		return fmt.Errorf("%w: bucket %q", propagator.ErrInvalidObjectName, bucket)
+	}
+	return nil
+}
+
+// validSegment rejects empty, relative and OS-specific path elements
+func validSegment(seg string) bool {
+	return seg != "" && seg != "." && seg != ".." && !strings.ContainsAny(seg, "\\:\x00")
+}
+
+// ============================================================================
+// Errors and Readers
+// ============================================================================
+
+// notFound maps a missing file to ErrObjectNotFound, keeping the OS error in the chain
+func notFound(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %w", propagator.ErrObjectNotFound, err)
+	}
+	return err
+}
+
+// contextError reports an operation abandoned because ctx ended
+func contextError(op, bucket, key string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return propagator.NewStorageError(op, bucket, key, err, propagator.AsTimeout(), propagator.AsTemporary())
+	}
+	return propagator.NewStorageError(op, bucket, key, err)
+}
+
+// sectionReadCloser reads a range of a file and closes the file
+type sectionReadCloser struct {
+	*io.SectionReader
+	f *os.File
+}
+
+func (r sectionReadCloser) Close() error {
+	return r.f.Close()
+}
@@ -0,0 +1,325 @@
+package localstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	propagator "goKata/01-context-cancellation-concurrency/05-context-aware-error-propagator"
+)
+
+func openStore(t *testing.T, opts ...Option) (*Store, string) {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := Open(dir, append([]Option{WithSyncMode(SyncNone)}, opts...)...)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, dir
+}
+
+func readAll(t *testing.T, r io.ReadCloser) string {
+	t.Helper()
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	return string(data)
+}
+
+// ============================================================================
+// Object Tests
+// ============================================================================
+
+func TestStore_RoundTrip(t *testing.T) {
+	s, dir := openStore(t)
+	ctx := context.Background()
+
+	if err := s.UploadFile(ctx, "photos", "2024/cat.jpg", []byte("hello world")); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "photos", "2024", "cat.jpg")); err != nil {
+		t.Errorf("object should be stored under the bucket directory: %v", err)
+	}
+
+	body, err := s.DownloadFile(ctx, "photos", "2024/cat.jpg", nil)
+	if err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if got := readAll(t, body); got != "hello world" {
+		t.Errorf("got %q", got)
+	}
+
+	body, err = s.DownloadFile(ctx, "photos", "2024/cat.jpg", &propagator.ByteRange{Offset: 6, Length: 3})
+	if err != nil {
+		t.Fatalf("range download failed: %v", err)
+	}
+	if got := readAll(t, body); got != "wor" {
+		t.Errorf("got %q, want %q", got, "wor")
+	}
+
+	want, _ := propagator.ComputeChecksum(propagator.ChecksumSHA256, []byte("hello world"))
+	got, err := s.ObjectChecksum(ctx, "photos", "2024/cat.jpg", propagator.ChecksumSHA256)
+	if err != nil || !got.Equal(want) {
+		t.Errorf("ObjectChecksum() = %v, %v; want %v", got, err, want)
+	}
+
+	if info, err := s.StatFile(ctx, "photos", "2024/cat.jpg"); err != nil || info.Size != 11 {
+		t.Errorf("StatFile() = %+v, %v; want size 11", info, err)
+	}
+
+	if err := s.DeleteFile(ctx, "photos", "2024/cat.jpg"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := s.StatFile(ctx, "photos", "2024/cat.jpg"); !errors.Is(err, propagator.ErrObjectNotFound) {
+		t.Errorf("StatFile after delete: expected ErrObjectNotFound, got: %v", err)
+	}
+	_, err = s.DownloadFile(ctx, "photos", "2024/cat.jpg", nil)
+	var storageErr *propagator.StorageError
+	if !errors.As(err, &storageErr) || !errors.Is(err, propagator.ErrObjectNotFound) || !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected StorageError wrapping ErrObjectNotFound, got: %v", err)
+	}
+}
+
+func TestStore_InvalidRange(t *testing.T) {
+	s, _ := openStore(t)
+	ctx := context.Background()
+	_ = s.UploadFile(ctx, "b", "k", []byte("abc"))
+
+	_, err := s.DownloadFile(ctx, "b", "k", &propagator.ByteRange{Offset: 4})
+	if !errors.Is(err, propagator.ErrInvalidRange) {
+		t.Errorf("expected ErrInvalidRange, got: %v", err)
+	}
+}
+
+func TestStore_RejectsTraversal(t *testing.T) {
+	s, dir := openStore(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		bucket string
+		key    string
+	}{
+		{bucket: "..", key: "k"},
+		{bucket: ".tmp", key: "k"},
+		{bucket: "a/b", key: "k"},
+		{bucket: "", key: "k"},
+		{bucket: "b", key: "../../etc/passwd"},
+		{bucket: "b", key: "x/../../y"},
+		{bucket: "b", key: "/abs"},
+		{bucket: "b", key: "dir/"},
+		{bucket: "b", key: "a//b"},
+		{bucket: "b", key: `..\..\win`},
+		{bucket: "b", key: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.bucket+"|"+tt.key, func(t *testing.T) {
+			err := s.UploadFile(ctx, tt.bucket, tt.key, []byte("x"))
+			var storageErr *propagator.StorageError
+			if !errors.As(err, &storageErr) || !errors.Is(err, propagator.ErrInvalidObjectName) {
+				t.Errorf("expected StorageError wrapping ErrInvalidObjectName, got: %v", err)
+			}
+			if _, err := s.DownloadFile(ctx, tt.bucket, tt.key, nil); !errors.Is(err, propagator.ErrInvalidObjectName) {
+				t.Errorf("download should reject the name too, got: %v", err)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "etc")); err == nil {
+		t.Error("nothing may be written outside the root")
+	}
+}
+
+func TestStore_CancelledUploadKeepsExistingObject(t *testing.T) {
+	s, _ := openStore(t)
+	_ = s.UploadFile(context.Background(), "b", "k", []byte("original"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := s.UploadFile(ctx, "b", "k", []byte("replacement"))
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+	body, _ := s.DownloadFile(context.Background(), "b", "k", nil)
+	if got := readAll(t, body); got != "original" {
+		t.Errorf("got %q, want the original object", got)
+	}
+}
+
+func TestOpen_ClearsLeftoverTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, tmpDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, tmpDir, "partial"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, tmpDir, "partial")); !os.IsNotExist(err) {
+		t.Error("leftover temp files should be removed on open")
+	}
+}
+
+// ============================================================================
+// Quota Tests
+// ============================================================================
+
+func TestStore_BucketQuota(t *testing.T) {
+	s, _ := openStore(t, WithBucketQuota("small", 10))
+	ctx := context.Background()
+
+	if err := s.UploadFile(ctx, "small", "a", []byte("123456")); err != nil {
+		t.Fatalf("upload within quota failed: %v", err)
+	}
+
+	err := s.UploadFile(ctx, "small", "b", []byte("123456"))
+	var quotaErr *propagator.StorageQuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.CurrentUsage != 6 || quotaErr.Limit != 10 {
+		t.Fatalf("expected StorageQuotaError at 6/10, got: %v", err)
+	}
+	if !errors.Is(err, propagator.ErrQuotaExceeded) {
+		t.Error("quota errors should wrap ErrQuotaExceeded")
+	}
+
+	// Replacing an object only counts the difference
+	if err := s.UploadFile(ctx, "small", "a", []byte("1234567890")); err != nil {
+		t.Errorf("overwrite within quota failed: %v", err)
+	}
+	if err := s.DeleteFile(ctx, "small", "a"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if usage, _ := s.Usage("small"); usage != 0 {
+		t.Errorf("usage after delete = %d, want 0", usage)
+	}
+	if err := s.UploadFile(ctx, "unlimited", "big", make([]byte, 100)); err != nil {
+		t.Errorf("buckets without a quota are unlimited, got: %v", err)
+	}
+}
+
+func TestStore_QuotaHoldsUnderConcurrency(t *testing.T) {
+	s, _ := openStore(t, WithBucketQuota("b", 50))
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Go(func() {
+			_ = s.UploadFile(context.Background(), "b", string(rune('a'+i)), make([]byte, 10))
+		})
+	}
+	wg.Wait()
+
+	if usage, _ := s.Usage("b"); usage > 50 {
+		t.Errorf("usage %d exceeds the quota", usage)
+	}
+}
+
+func TestStore_UsageIsLoadedFromDisk(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(dir, WithSyncMode(SyncNone))
+	_ = s.UploadFile(context.Background(), "b", "k", make([]byte, 8))
+	s.Close()
+
+	reopened, err := Open(dir, WithBucketQuota("b", 10))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer reopened.Close()
+
+	err = reopened.UploadFile(context.Background(), "b", "k2", make([]byte, 4))
+	var quotaErr *propagator.StorageQuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.CurrentUsage != 8 {
+		t.Errorf("existing objects should count against the quota, got: %v", err)
+	}
+}
+
+// ============================================================================
+// Gateway Integration
+// ============================================================================
+
+// staticAuth accepts every token as the same user
+type staticAuth struct{}
+
+func (staticAuth) ValidateToken(ctx context.Context, token string) (string, error) {
+	return "alice", nil
+}
+
+func TestStore_ThroughGatewayWithChecksum(t *testing.T) {
+	s, _ := openStore(t)
+	sum, _ := propagator.ComputeChecksum(propagator.ChecksumCRC32C, []byte("hello"))
+	metadata := &singleRecordMetadata{}
+	gateway := propagator.NewCloudStorageGateway(staticAuth{}, metadata, s)
+
+	err := gateway.UploadFile(context.Background(), propagator.FileUploadRequest{
+		Token:    "tok",
+		FileName: "a.txt",
+		Bucket:   "b",
+		Data:     []byte("hello"),
+		Checksum: &sum,
+	})
+
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if metadata.status != propagator.StatusCompleted {
+		t.Errorf("status = %q, want completed", metadata.status)
+	}
+}
+
+func TestStore_AcceptsClientObjectKeys(t *testing.T) {
+	s, _ := openStore(t)
+	ctx := context.Background()
+
+	for _, userID := range []string{"alice", "google:123", "..", ".", "a/b", `c:\d`} {
+		key := propagator.ClientObjectKey(userID, "reports/1")
+		if err := s.UploadFile(ctx, "b", key, []byte(userID)); err != nil {
+			t.Errorf("user %q: key %q should be storable, got: %v", userID, key, err)
+			continue
+		}
+		if info, err := s.StatFile(ctx, "b", key); err != nil || info.Size != int64(len(userID)) {
+			t.Errorf("user %q: expected the user's own object, got %+v, %v", userID, info, err)
+		}
+	}
+}
+
+// singleRecordMetadata tracks the status of one file
+type singleRecordMetadata struct {
+	status propagator.FileStatus
+}
+
+func (m *singleRecordMetadata) CreateFileRecord(ctx context.Context, rec propagator.FileRecord) (string, error) {
+	return "file-1", nil
+}
+
+func (m *singleRecordMetadata) UpdateFileStatus(ctx context.Context, fileID string, status propagator.FileStatus) error {
+	m.status = status
+	return nil
+}
+
+func (m *singleRecordMetadata) GetFileRecord(ctx context.Context, fileID string) (propagator.FileRecord, error) {
+	return propagator.FileRecord{}, propagator.NewMetadataError("get", fileID, propagator.ErrFileNotFound)
+}
+
+func (m *singleRecordMetadata) DeleteFileRecord(ctx context.Context, fileID string) error {
+	return nil
+}
+
+func (m *singleRecordMetadata) ListFileRecords(ctx context.Context, userID string, page propagator.PageRequest) (propagator.FileRecordPage, error) {
+	return propagator.FileRecordPage{}, nil
+}
+
+func (m *singleRecordMetadata) ListRecordsByStatus(ctx context.Context, status propagator.FileStatus, page propagator.PageRequest) (propagator.FileRecordPage, error) {
+	return propagator.FileRecordPage{}, nil
+}
@@ -0,0 +1,213 @@
+package propagator
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// Structured Logging
+// ============================================================================
+
+// LogValue renders the AuthError as a structured group
+// The API key is never emitted, only whether one was present
+func (e *AuthError) LogValue() slog.Value {
+	r := Redact(e).(*AuthError)
+	attrs := []slog.Attr{
+		slog.String("op", r.Op),
+		slog.String("user_id", r.UserID),
+	}
+	if r.APIKey != "" {
+		attrs = append(attrs, slog.String("api_key", r.APIKey))
+	}
+	attrs = append(attrs,
+		slog.Bool("timeout", e.isTimeout),
+		slog.Bool("temporary", e.isTemp),
+	)
+	return errorGroup(e, attrs, e.Err)
+}
+
+// LogValue renders the MetadataError as a structured group
+func (e *MetadataError) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("op", e.Op),
+		slog.String("file_id", e.FileID),
+		slog.Bool("temporary", e.isTemp),
+	}
+	if e.retryAfter > 0 {
+		attrs = append(attrs, slog.Duration("retry_after", e.retryAfter))
+	}
+	return errorGroup(e, attrs, e.Err)
+}
+
+// LogValue renders the StorageError as a structured group
+func (e *StorageError) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("op", e.Op),
+		slog.String("bucket", e.Bucket),
+		slog.String("key", e.Key),
+		slog.Bool("timeout", e.isTimeout),
+		slog.Bool("temporary", e.isTemp),
+	}
+	if e.retryAfter > 0 {
+		attrs = append(attrs, slog.Duration("retry_after", e.retryAfter))
+	}
+	return errorGroup(e, attrs, e.Err)
+}
+
+// LogValue renders the StorageQuotaError as a structured group
+func (e *StorageQuotaError) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("bucket", e.Bucket),
+		slog.Int64("usage", e.CurrentUsage),
+		slog.Int64("limit", e.Limit),
+	}
+	return errorGroup(e, attrs, e.Err)
+}
+
+// LogValue renders the IntegrityError as a structured group
+func (e *IntegrityError) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("op", e.Op),
+		slog.String("bucket", e.Bucket),
+		slog.String("key", e.Key),
+		slog.String("expected", e.Expected.String()),
+		slog.String("actual", e.Actual.String()),
+		slog.Bool("temporary", false),
+	}
+	return errorGroup(e, attrs, e.Err)
+}
+
+// LogValue renders the PermissionError as a structured group
+func (e *PermissionError) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("user_id", e.UserID),
+		slog.String("action", string(e.Action)),
+		slog.String("bucket", e.Bucket),
+		slog.Bool("temporary", false),
+	}
+	return errorGroup(e, attrs, e.Err)
+}
+
+// LogValue renders the StatusTransitionError as a structured group
+func (e *StatusTransitionError) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("from", string(e.From)),
+		slog.String("to", string(e.To)),
+		slog.Bool("temporary", false),
+	}
+	return errorGroup(e, attrs, e.Err)
+}
+
+// LogValue renders the ValidationError as a structured group
+func (e *ValidationError) LogValue() slog.Value {
+	rules := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		rules[i] = v.Rule
+	}
+	attrs := []slog.Attr{
+		slog.String("bucket", e.Bucket),
+		slog.String("file_name", e.FileName),
+		slog.String("rules", strings.Join(rules, ",")),
+		slog.Bool("temporary", false),
+	}
+	return errorGroup(e, attrs, e.Err)
+}
+
+// LogValue renders the KeyProviderError as a structured group
+func (e *KeyProviderError) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("op", e.Op),
+		slog.String("key_id", e.KeyID),
+		slog.Bool("timeout", e.isTimeout),
+		slog.Bool("temporary", e.isTemp),
+	}
+	return errorGroup(e, attrs, e.Err)
+}
+
+// errorGroup wraps attrs in a group led by err's code and followed by the wrapped error message, if any
+func errorGroup(err error, attrs []slog.Attr, cause error) slog.Value {
+	group := make([]slog.Attr, 0, len(attrs)+2)
+	group = append(group, slog.String("code", string(Code(err))))
+	group = append(group, attrs...)
+	if cause != nil {
+		group = append(group, slog.String("cause", cause.Error()))
+	}
+	return slog.GroupValue(group...)
+}
+
+// ChainAttrs flattens every error in err's tree into one attribute group per error
+// Groups are keyed by their position in a depth-first walk ("0" is err itself)
+// and carry the Go type plus either the error's LogValue attributes or its own
+// message with the wrapped part trimmed off, and the creation site when a
+// stack was captured
+func ChainAttrs(err error) []slog.Attr {
+	var attrs []slog.Attr
+	walkChain(err, func(e error) {
+		group := []slog.Attr{slog.String("type", fmt.Sprintf("%T", e))}
+		if lv, ok := e.(slog.LogValuer); ok {
+			v := lv.LogValue().Resolve()
+			if v.Kind() == slog.KindGroup {
+				group = append(group, v.Group()...)
+			} else {
+				group = append(group, slog.Any("value", v))
+			}
+		} else if msg := ownMessage(e); msg != "" {
+			group = append(group, slog.String("msg", msg))
+		}
+		if st, ok := e.(interface{ location() string }); ok {
+			if loc := st.location(); loc != "" {
+				group = append(group, slog.String("source", loc))
+			}
+		}
+		attrs = append(attrs, slog.Attr{Key: strconv.Itoa(len(attrs)), Value: slog.GroupValue(group...)})
+	})
+	return attrs
+}
+
+// ErrorChain returns a single attribute holding ChainAttrs(err) under key
+func ErrorChain(key string, err error) slog.Attr {
+	return slog.Attr{Key: key, Value: slog.GroupValue(ChainAttrs(err)...)}
+}
+
+// walkChain visits err and everything it wraps in depth-first order
+// Both Unwrap() error and Unwrap() []error (errors.Join) are followed
+func walkChain(err error, visit func(error)) {
+	if err == nil {
+		return
+	}
+	visit(err)
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		walkChain(u.Unwrap(), visit)
+	case interface{ Unwrap() []error }:
+		for _, child := range u.Unwrap() {
+			walkChain(child, visit)
+		}
+	}
+}
+
+// ownMessage returns the part of err's message contributed by err itself
+// Wrappers that follow the "context: %w" convention have the wrapped message trimmed
+func ownMessage(err error) string {
+	msg := err.Error()
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		if inner := u.Unwrap(); inner != nil {
+			msg = strings.TrimSuffix(msg, inner.Error())
+			msg = strings.TrimSuffix(msg, ": ")
+		}
+	case interface{ Unwrap() []error }:
+		// errors.Join contributes nothing but newlines between its children
+		var parts []string
+		for _, child := range u.Unwrap() {
+			parts = append(parts, child.Error())
+		}
+		if msg == strings.Join(parts, "\n") {
+			return ""
+		}
+	}
+	return msg
+}
@@ -0,0 +1,148 @@
+package propagator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// logJSON logs args through a JSON handler and decodes the resulting record
+func logJSON(t *testing.T, args ...any) (string, map[string]any) {
+	t.Helper()
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Error("upload failed", args...)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("invalid JSON log line: %v\n%s", err, buf.String())
+	}
+	return buf.String(), record
+}
+
+// ============================================================================
+// LogValue Tests
+// ============================================================================
+
+func TestAuthError_LogValueRedactsAPIKey(t *testing.T) {
+	secretKey := "sk-super-secret-api-key-12345"
+	authErr := &AuthError{
+		Op:        "validate_token",
+		UserID:    "user123",
+		APIKey:    secretKey,
+		Err:       ErrTokenExpired,
+		isTimeout: true,
+	}
+
+	line, record := logJSON(t, "err", authErr)
+
+	if strings.Contains(line, secretKey) {
+		t.Fatalf("SENSITIVE DATA LEAK: log line contains API key\nGot: %s", line)
+	}
+
+	group, ok := record["err"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected err to be a group, got: %#v", record["err"])
+	}
+	if group["op"] != "validate_token" || group["user_id"] != "user123" {
+		t.Errorf("missing identifying fields: %v", group)
+	}
+	if group["api_key"] != "[REDACTED]" {
+		t.Errorf("expected api_key=[REDACTED], got %v", group["api_key"])
+	}
+	if group["timeout"] != true || group["temporary"] != false {
+		t.Errorf("unexpected classification flags: %v", group)
+	}
+}
+
+func TestErrorTypes_LogValueFields(t *testing.T) {
+	tests := []struct {
+		name string
+		err  slog.LogValuer
+		want map[string]any
+	}{
+		{
+			name: "MetadataError",
+			err:  &MetadataError{Op: "insert", FileID: "file123", Err: ErrDatabaseDeadlock, isTemp: true},
+			want: map[string]any{"op": "insert", "file_id": "file123", "temporary": true},
+		},
+		{
+			name: "StorageError",
+			err:  &StorageError{Op: "upload", Bucket: "b", Key: "k", Err: ErrStorageUnavailable},
+			want: map[string]any{"op": "upload", "bucket": "b", "key": "k", "timeout": false},
+		},
+		{
+			name: "StorageQuotaError",
+			err:  &StorageQuotaError{Bucket: "b", CurrentUsage: 100, Limit: 50, Err: ErrQuotaExceeded},
+			want: map[string]any{"bucket": "b", "usage": float64(100), "limit": float64(50)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, record := logJSON(t, "err", tt.err)
+			group := record["err"].(map[string]any)
+			for k, v := range tt.want {
+				if group[k] != v {
+					t.Errorf("%s = %v, want %v", k, group[k], v)
+				}
+			}
+		})
+	}
+}
+
+// ============================================================================
+// ChainAttrs Tests
+// ============================================================================
+
+func TestChainAttrs_FlattensWrappedChain(t *testing.T) {
+	secretKey := "sk-super-secret-api-key-12345"
+	err := WrapWithContext(&AuthError{
+		Op:     "validate_token",
+		UserID: "user123",
+		APIKey: secretKey,
+		Err:    ErrInvalidToken,
+	}, "upload failed: auth")
+
+	attrs := ChainAttrs(err)
+	if len(attrs) != 3 {
+		t.Fatalf("expected 3 groups (wrapper, AuthError, sentinel), got %d", len(attrs))
+	}
+
+	line, record := logJSON(t, ErrorChain("error", err))
+	if strings.Contains(line, secretKey) {
+		t.Fatalf("SENSITIVE DATA LEAK: chain contains API key\nGot: %s", line)
+	}
+
+	chain := record["error"].(map[string]any)
+	wrapper := chain["0"].(map[string]any)
+	if wrapper["msg"] != "upload failed: auth" {
+		t.Errorf("wrapper msg should only hold its own context, got %v", wrapper["msg"])
+	}
+	auth := chain["1"].(map[string]any)
+	if auth["type"] != "*propagator.AuthError" || auth["user_id"] != "user123" {
+		t.Errorf("unexpected AuthError group: %v", auth)
+	}
+	sentinel := chain["2"].(map[string]any)
+	if sentinel["msg"] != ErrInvalidToken.Error() {
+		t.Errorf("unexpected sentinel group: %v", sentinel)
+	}
+}
+
+func TestChainAttrs_FollowsJoinedErrors(t *testing.T) {
+	err := errors.Join(
+		&StorageError{Op: "upload", Bucket: "b", Key: "k1", Err: ErrStorageUnavailable},
+		&MetadataError{Op: "update", FileID: "f2", Err: ErrDatabaseDeadlock},
+	)
+
+	attrs := ChainAttrs(err)
+	// join, StorageError, its sentinel, MetadataError, its sentinel
+	if len(attrs) != 5 {
+		t.Fatalf("expected 5 groups, got %d", len(attrs))
+	}
+	if ChainAttrs(nil) != nil {
+		t.Error("ChainAttrs(nil) should return nil")
+	}
+}
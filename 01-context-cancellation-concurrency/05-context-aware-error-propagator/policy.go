@@ -0,0 +1,155 @@
+package propagator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ============================================================================
+// Authorization
+// ============================================================================
+
+// Action is an operation a user can be allowed to perform on a bucket
+type Action string
+
+// Actions checked by the gateway
+const (
+	ActionUpload   Action = "upload"
+	ActionDownload Action = "download"
+	ActionDelete   Action = "delete"
+	ActionList     Action = "list"
+)
+
+// Policy decides whether an authenticated user may perform an action on a bucket
+// It is consulted after token validation. An empty bucket means the action is
+// not scoped to a single bucket (e.g., listing all of the user's files)
+type Policy interface {
+	// Authorize returns nil if the action is allowed
+	// Returns PermissionError wrapping ErrPermissionDenied on denial; any other
+	// error means the decision could not be made
+	Authorize(ctx context.Context, userID string, action Action, bucket string) error
+}
+
+// PermissionError reports that an authenticated user is not allowed to perform an action
+type PermissionError struct {
+	UserID string
+	Action Action
+	Bucket string
+	Err    error
+	stack  stack
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Errorf("permission error: user %s may not %s in bucket %s: %w", e.UserID, e.Action, e.Bucket, e.Err).Error()
+}
+
+func (e *PermissionError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary always returns false: retrying a denied action cannot succeed
+func (e *PermissionError) Temporary() bool {
+	return false
+}
+
+// Sentinel errors for authorization
+var (
+	ErrPermissionDenied = NewSentinel(CodePermissionDenied, "permission denied")
+	ErrUnknownRole      = NewSentinel(CodeUnknownRole, "unknown role")
+)
+
+// AllBuckets grants a Role on every bucket
+const AllBuckets = "*"
+
+// Role is a named set of actions allowed on a set of buckets
+type Role struct {
+	Name    string
+	Actions []Action
+	Buckets []string // Bucket names, or AllBuckets
+}
+
+// allows reports whether the role permits action on bucket
+// An empty bucket matches any bucket the role covers
+func (r Role) allows(action Action, bucket string) bool {
+	if !slices.Contains(r.Actions, action) {
+		return false
+	}
+	return bucket == "" || slices.Contains(r.Buckets, AllBuckets) || slices.Contains(r.Buckets, bucket)
+}
+
+// RBACPolicy is an in-memory role-based Policy
+// It is safe for concurrent use
+type RBACPolicy struct {
+	mu    sync.RWMutex
+	roles map[string]Role
+	users map[string][]string // userID -> role names
+}
+
+// NewRBACPolicy creates a policy with the given roles and no assignments
+func NewRBACPolicy(roles ...Role) *RBACPolicy {
+	p := &RBACPolicy{
+		roles: make(map[string]Role, len(roles)),
+		users: make(map[string][]string),
+	}
+	for _, r := range roles {
+		p.roles[r.Name] = r
+	}
+	return p
+}
+
+// Grant assigns roles to userID
+// Returns ErrUnknownRole, and grants nothing, if any role is not defined
+func (p *RBACPolicy) Grant(userID string, roles ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, name := range roles {
+		if _, ok := p.roles[name]; !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownRole, name)
+		}
+	}
+	for _, name := range roles {
+		if !slices.Contains(p.users[userID], name) {
+			p.users[userID] = append(p.users[userID], name)
+		}
+	}
+	return nil
+}
+
+// Revoke removes a role from userID
+func (p *RBACPolicy) Revoke(userID, role string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.users[userID] = slices.DeleteFunc(p.users[userID], func(name string) bool { return name == role })
+}
+
+// Authorize allows the action if any of the user's roles allows it
+func (p *RBACPolicy) Authorize(ctx context.Context, userID string, action Action, bucket string) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, name := range p.users[userID] {
+		if p.roles[name].allows(action, bucket) {
+			return nil
+		}
+	}
+	return NewPermissionError(userID, action, bucket)
+}
+
+// WithPolicy enables authorization checks after token validation
+// Without a Policy every authenticated user may perform every action
+func WithPolicy(policy Policy) Option {
+	return func(g *CloudStorageGateway) {
+		g.policy = policy
+	}
+}
+
+// authorize consults the configured Policy, if any
+func (g *CloudStorageGateway) authorize(ctx context.Context, userID string, action Action, bucket string) error {
+	if g.policy == nil {
+		return nil
+	}
+	return g.policy.Authorize(ctx, userID, action, bucket)
+}
@@ -0,0 +1,177 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func testPolicy(t *testing.T) *RBACPolicy {
+	t.Helper()
+	p := NewRBACPolicy(
+		Role{Name: "writer", Actions: []Action{ActionUpload, ActionList}, Buckets: []string{"my-bucket"}},
+		Role{Name: "admin", Actions: []Action{ActionUpload, ActionDownload, ActionDelete, ActionList}, Buckets: []string{AllBuckets}},
+	)
+	if err := p.Grant("user-alice", "writer"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := p.Grant("user-root", "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return p
+}
+
+// ============================================================================
+// RBAC Policy Tests
+// ============================================================================
+
+func TestRBACPolicy_Authorize(t *testing.T) {
+	p := testPolicy(t)
+
+	tests := []struct {
+		name    string
+		userID  string
+		action  Action
+		bucket  string
+		allowed bool
+	}{
+		{name: "granted bucket", userID: "user-alice", action: ActionUpload, bucket: "my-bucket", allowed: true},
+		{name: "other bucket", userID: "user-alice", action: ActionUpload, bucket: "other-bucket"},
+		{name: "action not in role", userID: "user-alice", action: ActionDelete, bucket: "my-bucket"},
+		{name: "unscoped action", userID: "user-alice", action: ActionList, bucket: "", allowed: true},
+		{name: "wildcard bucket", userID: "user-root", action: ActionDelete, bucket: "any-bucket", allowed: true},
+		{name: "no roles", userID: "user-bob", action: ActionList, bucket: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Authorize(context.Background(), tt.userID, tt.action, tt.bucket)
+			if tt.allowed {
+				if err != nil {
+					t.Errorf("expected allowed, got: %v", err)
+				}
+				return
+			}
+			var permErr *PermissionError
+			if !errors.As(err, &permErr) || !errors.Is(err, ErrPermissionDenied) {
+				t.Fatalf("expected PermissionError, got: %v", err)
+			}
+			if permErr.UserID != tt.userID || permErr.Action != tt.action || permErr.Bucket != tt.bucket {
+				t.Errorf("unexpected PermissionError fields: %+v", permErr)
+			}
+		})
+	}
+}
+
+func TestRBACPolicy_GrantUnknownRole(t *testing.T) {
+	p := testPolicy(t)
+
+	err := p.Grant("user-bob", "writer", "superuser")
+	if !errors.Is(err, ErrUnknownRole) {
+		t.Fatalf("expected ErrUnknownRole, got: %v", err)
+	}
+	if p.Authorize(context.Background(), "user-bob", ActionUpload, "my-bucket") == nil {
+		t.Error("a failed Grant must not assign any role")
+	}
+}
+
+func TestRBACPolicy_Revoke(t *testing.T) {
+	p := testPolicy(t)
+	p.Revoke("user-alice", "writer")
+
+	if p.Authorize(context.Background(), "user-alice", ActionUpload, "my-bucket") == nil {
+		t.Error("revoked role should no longer allow the action")
+	}
+}
+
+func TestPermissionError_NeverTemporary(t *testing.T) {
+	// Even a temporary cause is overridden by the denial
+	err := WrapWithContext(&PermissionError{UserID: "u", Action: ActionUpload, Bucket: "b", Err: ErrStorageUnavailable}, "upload failed")
+	if IsTemporary(err) {
+		t.Error("PermissionError must never be classified as temporary")
+	}
+	if Code(NewPermissionError("u", ActionUpload, "b")) != CodePermissionDenied {
+		t.Errorf("Code() = %s, want %s", Code(NewPermissionError("u", ActionUpload, "b")), CodePermissionDenied)
+	}
+}
+
+func TestPermissionError_LogValue(t *testing.T) {
+	var buf strings.Builder
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	logger.Error("denied", "error", NewPermissionError("user-alice", ActionDelete, "my-bucket"))
+
+	for _, want := range []string{"error.code=PERMISSION_DENIED", "error.action=delete", "error.bucket=my-bucket", "error.temporary=false"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log output missing %q: %s", want, buf.String())
+		}
+	}
+}
+
+// ============================================================================
+// Gateway Authorization Tests
+// ============================================================================
+
+func TestCloudStorageGateway_UploadFile_DeniedByPolicy(t *testing.T) {
+	metadata := &countingMetadataService{mockMetadataService: mockMetadataService{fileID: "file456"}}
+	gateway := NewCloudStorageGateway(
+		&mockAuthService{userID: "user-alice"},
+		metadata,
+		&mockStorageService{},
+		WithPolicy(testPolicy(t)),
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "other-bucket",
+		Data:     []byte("hello world"),
+	})
+
+	var permErr *PermissionError
+	if !errors.As(err, &permErr) {
+		t.Fatalf("expected PermissionError, got: %v", err)
+	}
+	if metadata.creates != 0 {
+		t.Error("a denied upload must not reach the metadata service")
+	}
+	if !strings.Contains(fmt.Sprint(err), "upload failed: permission") {
+		t.Errorf("missing gateway context: %v", err)
+	}
+}
+
+func TestCloudStorageGateway_UploadFiles_PolicyAppliesPerFile(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store, WithPolicy(testPolicy(t)))
+
+	reqs := batchRequests(2, "alice")
+	reqs[1].Bucket = "other-bucket"
+	results, err := gateway.UploadFiles(context.Background(), reqs)
+
+	if results[0].Err != nil {
+		t.Errorf("allowed file should upload, got: %v", results[0].Err)
+	}
+	if !errors.Is(results[1].Err, ErrPermissionDenied) {
+		t.Errorf("denied file should fail with ErrPermissionDenied, got: %v", results[1].Err)
+	}
+	if IsTemporary(err) {
+		t.Error("a batch failing only on permissions is not temporary")
+	}
+}
+
+func TestCloudStorageGateway_DeleteFile_DeniedByPolicy(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store, WithPolicy(testPolicy(t)))
+	fileID := uploadTestFile(t, gateway, "alice", []byte("hello world"))
+
+	err := gateway.DeleteFile(context.Background(), "alice", fileID)
+
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("expected ErrPermissionDenied, got: %v", err)
+	}
+	if status := store.records[fileID].Status; status != StatusCompleted {
+		t.Errorf("a denied delete must not touch the record, got status %q", status)
+	}
+}
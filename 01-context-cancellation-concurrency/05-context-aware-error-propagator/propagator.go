@@ -2,8 +2,13 @@ package propagator
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/sync/errgroup"
 )
 
 // ============================================================================
@@ -14,19 +19,21 @@ import (
 type AuthError struct {
 	Op        string // Operation that failed (e.g., "validate_token", "refresh_token")
 	UserID    string // User identifier (safe for logging)
-	APIKey    string // API key (must be redacted in Error())
+	APIKey    string `redact:"true"` // API key (redacted in every rendering)
 	Err       error  // Underlying error
 	isTimeout bool
 	isTemp    bool
+	stack     stack
 }
 
 func (e *AuthError) Error() string {
-	// TODO: Implement error message that redacts sensitive info (APIKey)
-	redactedKey := "[REDACTED]"
-	if e.APIKey != "" {
-		redactedKey = ""
+	// Only the redacted copy is ever rendered; the key is mentioned only when present
+	r := Redact(e).(*AuthError)
+	key := ""
+	if r.APIKey != "" {
+		key = fmt.Sprintf(" (key=%s)", r.APIKey)
 	}
-	return fmt.Errorf("auth error during %s for user %s (key=%s): %w", e.Op, e.UserID, redactedKey, e.Err).Error()
+	return fmt.Errorf("auth error during %s for user %s%s: %w", r.Op, r.UserID, key, r.Err).Error()
 }
 
 func (e *AuthError) Unwrap() error {
@@ -46,10 +53,12 @@ func (e *AuthError) Temporary() bool {
 
 // MetadataError represents metadata service errors (database operations)
 type MetadataError struct {
-	Op     string // Operation (e.g., "insert", "update", "query")
-	FileID string // File identifier
-	Err    error  // Underlying error
-	isTemp bool
+	Op         string // Operation (e.g., "insert", "update", "query")
+	FileID     string // File identifier
+	Err        error  // Underlying error
+	isTemp     bool
+	retryAfter time.Duration
+	stack      stack
 }
 
 func (e *MetadataError) Error() string {
@@ -67,14 +76,22 @@ func (e *MetadataError) Temporary() bool {
 	return e.isTemp
 }
 
+// RetryAfter returns how long the backend asked callers to wait before retrying
+// Zero means no hint was given
+func (e *MetadataError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
 // StorageError represents blob storage errors
 type StorageError struct {
-	Op        string // Operation (e.g., "upload", "download", "delete")
-	Bucket    string // Storage bucket
-	Key       string // Object key
-	Err       error  // Underlying error
-	isTimeout bool
-	isTemp    bool
+	Op         string // Operation (e.g., "upload", "download", "delete")
+	Bucket     string // Storage bucket
+	Key        string // Object key
+	Err        error  // Underlying error
+	isTimeout  bool
+	isTemp     bool
+	retryAfter time.Duration
+	stack      stack
 }
 
 func (e *StorageError) Error() string {
@@ -97,12 +114,19 @@ func (e *StorageError) Temporary() bool {
 	return e.isTemp
 }
 
+// RetryAfter returns how long the backend asked callers to wait before retrying
+// Zero means no hint was given
+func (e *StorageError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
 // StorageQuotaError represents quota exceeded errors
 type StorageQuotaError struct {
 	Bucket       string
 	CurrentUsage int64
 	Limit        int64
 	Err          error
+	stack        stack
 }
 
 func (e *StorageQuotaError) Error() string {
@@ -128,13 +152,36 @@ type AuthService interface {
 
 // MetadataService handles file metadata operations
 type MetadataService interface {
-	// CreateFileRecord creates a new file metadata entry
+	// CreateFileRecord creates a new file metadata entry from rec and returns its ID
+	// rec.ID and rec.CreatedAt are assigned by the service; new records start in StatusPending
+	// A non-empty rec.Key must be unique within rec.Bucket among all records,
+	// deleted tombstones included, so a record always owns the object under its key
+	// Returns MetadataError on failure, wrapping ErrKeyInUse if the key is taken
+	CreateFileRecord(ctx context.Context, rec FileRecord) (fileID string, err error)
+
+	// UpdateFileStatus moves the file to status
+	// Setting the current status again succeeds without a change. Returns
+	// MetadataError on failure, wrapping a StatusTransitionError if the
+	// change is not allowed (see CheckTransition)
+	UpdateFileStatus(ctx context.Context, fileID string, status FileStatus) error
+
+	// GetFileRecord returns the metadata entry for fileID
+	// Returns MetadataError wrapping ErrFileNotFound if there is none
+	GetFileRecord(ctx context.Context, fileID string) (FileRecord, error)
+
+	// DeleteFileRecord removes the metadata entry for fileID
+	// Returns MetadataError wrapping ErrFileNotFound if there is none
+	DeleteFileRecord(ctx context.Context, fileID string) error
+
+	// ListFileRecords returns one page of the user's records, ordered by ID
+	// An empty PageToken starts from the beginning; the returned token is empty on the last page
 	// Returns MetadataError on failure
-	CreateFileRecord(ctx context.Context, userID, fileName string, size int64) (fileID string, err error)
+	ListFileRecords(ctx context.Context, userID string, page PageRequest) (FileRecordPage, error)
 
-	// UpdateFileStatus updates the file upload status
+	// ListRecordsByStatus returns one page of the records in status across all users, ordered by ID
+	// It lets background jobs such as the Reconciler find records stuck in a status
 	// Returns MetadataError on failure
-	UpdateFileStatus(ctx context.Context, fileID, status string) error
+	ListRecordsByStatus(ctx context.Context, status FileStatus, page PageRequest) (FileRecordPage, error)
 }
 
 // StorageService handles blob storage operations
@@ -142,6 +189,24 @@ type StorageService interface {
 	// UploadFile uploads file content to storage
 	// Returns StorageError or StorageQuotaError on failure
 	UploadFile(ctx context.Context, bucket, key string, data []byte) error
+
+	// ObjectChecksum computes the checksum of the stored object with the given algorithm
+	// so callers can confirm the bytes at rest match what was sent
+	// Returns StorageError on failure
+	ObjectChecksum(ctx context.Context, bucket, key string, alg ChecksumAlgorithm) (Checksum, error)
+
+	// DownloadFile streams the object content, restricted to rng when it is non-nil
+	// The caller must close the returned reader
+	// Returns StorageError wrapping ErrObjectNotFound or ErrInvalidRange on failure
+	DownloadFile(ctx context.Context, bucket, key string, rng *ByteRange) (io.ReadCloser, error)
+
+	// StatFile reports whether the object exists and how large it is
+	// Returns StorageError wrapping ErrObjectNotFound if it does not exist
+	StatFile(ctx context.Context, bucket, key string) (ObjectInfo, error)
+
+	// DeleteFile removes the object
+	// Returns StorageError wrapping ErrObjectNotFound if it does not exist
+	DeleteFile(ctx context.Context, bucket, key string) error
 }
 
 // ============================================================================
@@ -154,53 +219,365 @@ type FileUploadRequest struct {
 	FileName string
 	Bucket   string
 	Data     []byte
+	Checksum *Checksum // Optional expected checksum of Data
+	Key      string    // Optional client-generated object key, stored as ClientObjectKey(user, Key); defaults to the file ID
 }
 
 // CloudStorageGateway coordinates file uploads across services
 type CloudStorageGateway struct {
-	auth     AuthService
-	metadata MetadataService
-	storage  StorageService
+	auth        AuthService
+	metadata    MetadataService
+	storage     StorageService
+	quota       QuotaService
+	policy      Policy
+	publisher   Publisher
+	outbox      Outbox
+	uploadRules map[string]UploadRules
+	timeouts    StepTimeouts
+	keys        KeyProvider
+	concurrent  bool
+}
+
+// Option configures optional gateway behavior
+type Option func(*CloudStorageGateway)
+
+// WithQuotaService enables pre-flight quota checks before metadata is written
+// Usage counts the bytes stored, which is the ciphertext when WithEncryption is used
+func WithQuotaService(quota QuotaService) Option {
+	return func(g *CloudStorageGateway) {
+		g.quota = quota
+	}
+}
+
+// WithConcurrentUpload overlaps metadata creation with the storage upload
+// for requests that carry a client-generated Key. The upload starts
+// speculatively once auth passes; if either step fails the other is cancelled.
+// Requests without a Key still run sequentially since their object key is the file ID
+func WithConcurrentUpload() Option {
+	return func(g *CloudStorageGateway) {
+		g.concurrent = true
+	}
 }
 
 // NewCloudStorageGateway creates a new gateway with the provided services
-func NewCloudStorageGateway(auth AuthService, metadata MetadataService, storage StorageService) *CloudStorageGateway {
-	return &CloudStorageGateway{
+func NewCloudStorageGateway(auth AuthService, metadata MetadataService, storage StorageService, opts ...Option) *CloudStorageGateway {
+	g := &CloudStorageGateway{
 		auth:     auth,
 		metadata: metadata,
 		storage:  storage,
 	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
 }
 
 // UploadFile handles the complete file upload flow
-// It validates auth, creates metadata, and uploads to storage
+// It validates auth and the request, creates metadata, and uploads to storage
 // Errors are wrapped with context at each layer
 func (g *CloudStorageGateway) UploadFile(ctx context.Context, req FileUploadRequest) error {
+	_, err := g.UploadFileResult(ctx, req)
+	return err
+}
+
+// UploadFileResult is UploadFile, also reporting the file ID and object key of the stored file
+// FileName in the result is the sanitized name the record was created with
+func (g *CloudStorageGateway) UploadFileResult(ctx context.Context, req FileUploadRequest) (UploadResult, error) {
 	// 1. Validate token
-	userID, err := g.auth.ValidateToken(ctx, req.Token)
+	authCtx, cancel := g.stepContext(ctx, g.timeouts.Auth)
+	defer cancel()
+	userID, err := g.auth.ValidateToken(authCtx, req.Token)
+	if err != nil {
+		return UploadResult{}, WrapWithContext(err, "upload failed: auth")
+	}
+	if err := g.authorize(authCtx, userID, ActionUpload, req.Bucket); err != nil {
+		return UploadResult{}, WrapWithContext(err, "upload failed: permission")
+	}
+
+	fileID, key, err := g.upload(ctx, userID, req)
+	if err != nil {
+		return UploadResult{}, err
+	}
+	return UploadResult{FileName: SanitizeFileName(req.FileName), FileID: fileID, Key: key}, nil
+}
+
+// upload runs the upload flow for an already authenticated user
+// It returns the file ID and the object key the content was stored under
+func (g *CloudStorageGateway) upload(ctx context.Context, userID string, req FileUploadRequest) (fileID, key string, err error) {
+	// 2. Apply the bucket's upload rules and sanitize the file name
+	name, err := g.validateUpload(req)
+	if err != nil {
+		return "", "", WrapWithContext(err, "upload failed: validation")
+	}
+	req.FileName = name
+	if req.Key != "" {
+		req.Key = ClientObjectKey(userID, req.Key)
+	}
+
+	// 3. Verify the request content before anything is written
+	if req.Checksum != nil {
+		if err := verifyChecksum("verify_request", req.Bucket, "", *req.Checksum, req.Data); err != nil {
+			return "", "", WrapWithContext(err, "upload failed: checksum")
+		}
+	}
+
+	// 4. Reserve quota so over-quota uploads never reach metadata
+	// Quota counts the bytes stored, so encrypted content is charged as ciphertext
+	size := int64(len(req.Data))
+	storedSize := size
+	if g.keys != nil {
+		storedSize = sealedSize(size)
+	}
+	reservationID, err := g.reserveQuota(ctx, req.Bucket, storedSize)
+	if err != nil {
+		return "", "", WrapWithContext(err, "upload failed: quota")
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			g.releaseQuota(ctx, reservationID)
+		}
+	}()
+
+	// 5. Encrypt the content under a fresh data key when a KeyProvider is configured
+	rec := newFileRecord(userID, req, size)
+	storedChecksum := req.Checksum
+	if g.keys != nil {
+		sealed, enc, err := g.encryptContent(ctx, req.Data)
+		if err != nil {
+			return "", "", WrapWithContext(err, "upload failed: encryption")
+		}
+		req.Data, rec.Encryption = sealed, enc
+		if storedChecksum != nil {
+			// The algorithm was accepted in step 3, and storage now holds the ciphertext
+			sum, _ := ComputeChecksum(storedChecksum.Algorithm, sealed)
+			storedChecksum = &sum
+		}
+	}
+
+	// 6-7. Create file record and upload to storage
+	fileID, key, err = g.createAndUpload(ctx, req, rec)
+	if err != nil {
+		return "", "", err
+	}
+
+	// 8. Confirm the stored bytes match the expected checksum
+	if storedChecksum != nil {
+		if err := g.verifyStoredChecksum(ctx, req.Bucket, key, *storedChecksum); err != nil {
+			g.markFailed(ctx, newUploadEvent(EventUploadFailed, fileID, userID, req, size, err))
+			g.discardObject(ctx, req.Bucket, key)
+			return "", "", WrapWithContext(err, "upload failed: checksum")
+		}
+	}
+
+	// 9. Turn the reservation into permanent usage
+	if reservationID != "" {
+		err := g.step(ctx, g.timeouts.Quota, func(ctx context.Context) error {
+			return g.quota.Commit(ctx, reservationID)
+		})
+		if err != nil {
+			g.markFailed(ctx, newUploadEvent(EventUploadFailed, fileID, userID, req, size, err))
+			g.discardObject(ctx, req.Bucket, key)
+			return "", "", WrapWithContext(err, "upload failed: quota commit")
+		}
+		committed = true
+	}
+
+	// 10. Update status on success
+	err = g.step(ctx, g.timeouts.Metadata, func(ctx context.Context) error {
+		return g.finishUpload(ctx, StatusCompleted, newUploadEvent(EventUploadCompleted, fileID, userID, req, size, nil))
+	})
 	if err != nil {
-		return WrapWithContext(err, "upload failed: auth")
+		// The record never reaches completed, so the reconciler will remove
+		// the object: the committed bytes must not outlive it
+		g.freeQuota(ctx, req.Bucket, storedSize)
+		return "", "", WrapWithContext(err, "upload failed: status update")
 	}
 
-	// 2. Create file record
-	fileID, err := g.metadata.CreateFileRecord(ctx, userID, req.FileName, int64(len(req.Data)))
+	return fileID, key, nil
+}
+
+// createAndUpload creates rec and uploads the content, returning the file ID
+// and the object key the content was stored under
+// Returned errors are already wrapped and compensated
+func (g *CloudStorageGateway) createAndUpload(ctx context.Context, req FileUploadRequest, rec FileRecord) (fileID, key string, err error) {
+	if req.Key != "" {
+		if err := g.checkKeyFree(ctx, req.Bucket, req.Key); err != nil {
+			return "", "", WrapWithContext(err, "upload failed: storage")
+		}
+	}
+	if g.concurrent && req.Key != "" {
+		return g.createAndUploadConcurrently(ctx, req, rec)
+	}
+
+	err = g.step(ctx, g.timeouts.Metadata, func(ctx context.Context) (err error) {
+		fileID, err = g.metadata.CreateFileRecord(ctx, rec)
+		return err
+	})
+	if err != nil {
+		return "", "", WrapWithContext(err, "create file record failed")
+	}
+	err = g.step(ctx, g.timeouts.Metadata, func(ctx context.Context) error {
+		return g.metadata.UpdateFileStatus(ctx, fileID, StatusUploading)
+	})
 	if err != nil {
-		return WrapWithContext(err, "create file record failed")
+		g.markFailed(ctx, newUploadEvent(EventUploadFailed, fileID, rec.UserID, req, rec.Size, err))
+		return "", "", WrapWithContext(err, "upload failed: status update")
 	}
 
-	// 3. Upload to storage
-	err = g.storage.UploadFile(ctx, req.Bucket, fileID, req.Data)
+	key = req.Key
+	if key == "" {
+		key = fileID
+	}
+	err = g.step(ctx, g.timeouts.Storage, func(ctx context.Context) error {
+		return g.storage.UploadFile(ctx, req.Bucket, key, req.Data)
+	})
 	if err != nil {
 		// Update status to "failed" before returning
-		_ = g.metadata.UpdateFileStatus(ctx, fileID, "failed")
-		return WrapWithContext(err, "upload failed: storage")
+		g.markFailed(ctx, newUploadEvent(EventUploadFailed, fileID, rec.UserID, req, rec.Size, err))
+		return "", "", WrapWithContext(err, "upload failed: storage")
+	}
+	return fileID, key, nil
+}
+
+// ClientObjectKey returns the object key a client-supplied key is stored under
+// Client keys live under the user ID, so users cannot reach each other's
+// objects and, containing a "/", never collide with file IDs. The user ID is
+// base64url-encoded so any ID forms a single safe path segment
+func ClientObjectKey(userID, key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(userID)) + "/" + key
+}
+
+// checkKeyFree refuses a client key that already holds an object, so an
+// upload never overwrites the content of another record
+// Records claim their key in CreateFileRecord; this check also covers
+// concurrent uploads, whose content is stored before the record exists
+func (g *CloudStorageGateway) checkKeyFree(ctx context.Context, bucket, key string) error {
+	err := g.step(ctx, g.timeouts.Storage, func(ctx context.Context) error {
+		_, err := g.storage.StatFile(ctx, bucket, key)
+		return err
+	})
+	switch {
+	case err == nil:
+		return NewStorageError("upload", bucket, key, ErrObjectExists)
+	case errors.Is(err, ErrObjectNotFound):
+		return nil
+	default:
+		return err
+	}
+}
+
+// createAndUploadConcurrently runs CreateFileRecord and the storage upload in parallel
+// The first failure cancels the other step. A record that was created is
+// marked "failed" exactly as in the sequential flow, and an uploaded object
+// is deleted again. When the key belongs to another record the object is
+// left to that record
+func (g *CloudStorageGateway) createAndUploadConcurrently(ctx context.Context, req FileUploadRequest, rec FileRecord) (string, string, error) {
+	var fileID string
+	var uploaded bool
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		var id string
+		err := g.step(egCtx, g.timeouts.Metadata, func(ctx context.Context) (err error) {
+			id, err = g.metadata.CreateFileRecord(ctx, rec)
+			return err
+		})
+		if err != nil {
+			return WrapWithContext(err, "create file record failed")
+		}
+		fileID = id
+		err = g.step(egCtx, g.timeouts.Metadata, func(ctx context.Context) error {
+			return g.metadata.UpdateFileStatus(ctx, id, StatusUploading)
+		})
+		if err != nil {
+			return WrapWithContext(err, "upload failed: status update")
+		}
+		return nil
+	})
+	eg.Go(func() error {
+		err := g.step(egCtx, g.timeouts.Storage, func(ctx context.Context) error {
+			return g.storage.UploadFile(ctx, req.Bucket, req.Key, req.Data)
+		})
+		if err != nil {
+			return WrapWithContext(err, "upload failed: storage")
+		}
+		uploaded = true
+		return nil
+	})
+
+	if err := eg.Wait(); err != nil {
+		// egCtx is cancelled by now; compensations run in their own cleanup budget
+		if fileID != "" {
+			g.markFailed(ctx, newUploadEvent(EventUploadFailed, fileID, rec.UserID, req, rec.Size, err))
+		}
+		if uploaded && !errors.Is(err, ErrKeyInUse) {
+			g.discardObject(ctx, req.Bucket, req.Key)
+		}
+		return "", "", err
+	}
+	return fileID, req.Key, nil
+}
+
+// reserveQuota reserves size bytes in bucket when a QuotaService is configured
+// It returns an empty reservation ID when quotas are disabled
+func (g *CloudStorageGateway) reserveQuota(ctx context.Context, bucket string, size int64) (string, error) {
+	if g.quota == nil {
+		return "", nil
 	}
+	var reservationID string
+	err := g.step(ctx, g.timeouts.Quota, func(ctx context.Context) (err error) {
+		reservationID, err = g.quota.Reserve(ctx, bucket, size)
+		return err
+	})
+	return reservationID, err
+}
 
-	// 4. Update status on success
-	if err := g.metadata.UpdateFileStatus(ctx, fileID, "completed"); err != nil {
-		return WrapWithContext(err, "upload failed: status update")
+// releaseQuota gives back an uncommitted reservation within the cleanup budget
+// Release failures are ignored: the upload has already failed with a more relevant error
+func (g *CloudStorageGateway) releaseQuota(ctx context.Context, reservationID string) {
+	if reservationID == "" {
+		return
 	}
+	_ = g.cleanup(ctx, func(ctx context.Context) error {
+		return g.quota.Release(ctx, reservationID)
+	})
+}
+
+// freeQuota gives back committed usage within the cleanup budget when a
+// QuotaService is configured
+// Free failures are ignored: the caller is already reporting a more relevant outcome
+func (g *CloudStorageGateway) freeQuota(ctx context.Context, bucket string, size int64) {
+	if g.quota == nil {
+		return
+	}
+	_ = g.cleanup(ctx, func(ctx context.Context) error {
+		return g.quota.Free(ctx, bucket, size)
+	})
+}
+
+// discardObject deletes the object of an upload whose record was marked failed,
+// within the cleanup budget
+// A failed record is never reconciled, so nothing else would remove the object.
+// Failures are ignored: the upload has already failed with a more relevant error
+func (g *CloudStorageGateway) discardObject(ctx context.Context, bucket, key string) {
+	_ = g.cleanup(ctx, func(ctx context.Context) error {
+		return g.storage.DeleteFile(ctx, bucket, key)
+	})
+}
 
+// verifyStoredChecksum asks storage for the checksum of the stored object and compares it
+func (g *CloudStorageGateway) verifyStoredChecksum(ctx context.Context, bucket, key string, expected Checksum) error {
+	var stored Checksum
+	err := g.step(ctx, g.timeouts.Storage, func(ctx context.Context) (err error) {
+		stored, err = g.storage.ObjectChecksum(ctx, bucket, key, expected.Algorithm)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !stored.Equal(expected) {
+		return NewIntegrityError("verify_stored", bucket, key, expected, stored)
+	}
 	return nil
 }
 
@@ -208,54 +585,40 @@ func (g *CloudStorageGateway) UploadFile(ctx context.Context, req FileUploadRequ
 // Helper Functions
 // ============================================================================
 
-// IsTimeout checks if the error (or any wrapped error) is a timeout
+// IsTimeout checks if the error (or any wrapped or joined error) is a timeout
+// It is ClassifyTimeout with the AnyBranch policy
 func IsTimeout(err error) bool {
-	// TODO: Check for timeout errors using errors.As
-	// Should work with AuthError, StorageError, and context.DeadlineExceeded
-	if err == nil {
-		return false
-	}
-	if errors.Is(err, context.DeadlineExceeded) {
-		return true
-	}
-	var te timeoutError
-	if errors.As(err, &te) && te.Timeout() {
-		return true
-	}
-	return false
+	return ClassifyTimeout(err, AnyBranch).Matched
 }
 
-// IsTemporary checks if the error (or any wrapped error) is temporary/retriable
+// IsTemporary checks if the error (or any wrapped or joined error) is temporary/retriable
+// It is ClassifyTemporary with the AnyBranch policy
 func IsTemporary(err error) bool {
-	// TODO: Check for temporary errors using errors.As
-	if err == nil {
-		return false
-	}
-	var te temporaryError
-	if errors.As(err, &te) && te.Temporary() {
-		return true
-	}
-	return false
+	return ClassifyTemporary(err, AnyBranch).Matched
 }
 
-// WrapWithContext wraps an error with additional context using fmt.Errorf and %w
+// WrapWithContext wraps an error with additional context, preserving the error chain
+// The caller's location is recorded when stack capture is enabled
 func WrapWithContext(err error, format string, args ...interface{}) error {
-	// TODO: Wrap error while preserving the error chain
 	if err == nil {
 		return nil
 	}
-	msg := fmt.Sprintf(format, args...)
-	return fmt.Errorf("%s: %w", msg, err)
+	return &wrappedError{
+		msg:   fmt.Sprintf(format, args...),
+		err:   err,
+		stack: captureStack(),
+	}
 }
 
 // Sentinel errors for common failure cases
+// Each carries a stable ErrorCode (see Code)
 var (
-	ErrAuthFailed         = errors.New("authentication failed")
-	ErrTokenExpired       = errors.New("token expired")
-	ErrInvalidToken       = errors.New("invalid token")
-	ErrDatabaseDeadlock   = errors.New("database deadlock")
-	ErrStorageUnavailable = errors.New("storage service unavailable")
-	ErrQuotaExceeded      = errors.New("storage quota exceeded")
+	ErrAuthFailed         = NewSentinel(CodeAuthFailed, "authentication failed")
+	ErrTokenExpired       = NewSentinel(CodeAuthTokenExpired, "token expired")
+	ErrInvalidToken       = NewSentinel(CodeAuthInvalidToken, "invalid token")
+	ErrDatabaseDeadlock   = NewSentinel(CodeMetadataDeadlock, "database deadlock")
+	ErrStorageUnavailable = NewSentinel(CodeStorageUnavailable, "storage service unavailable")
+	ErrQuotaExceeded      = NewSentinel(CodeQuotaExceeded, "storage quota exceeded")
 )
 
 // timeoutError interface for checking timeout errors
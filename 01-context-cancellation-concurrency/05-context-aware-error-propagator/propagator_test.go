@@ -1,11 +1,16 @@
 package propagator
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // ============================================================================
@@ -30,23 +35,74 @@ type mockMetadataService struct {
 	updateErr error
 }
 
-func (m *mockMetadataService) CreateFileRecord(ctx context.Context, userID, fileName string, size int64) (string, error) {
+func (m *mockMetadataService) CreateFileRecord(ctx context.Context, rec FileRecord) (string, error) {
 	if m.createErr != nil {
 		return "", m.createErr
 	}
 	return m.fileID, nil
 }
 
-func (m *mockMetadataService) UpdateFileStatus(ctx context.Context, fileID, status string) error {
+func (m *mockMetadataService) UpdateFileStatus(ctx context.Context, fileID string, status FileStatus) error {
 	return m.updateErr
 }
 
+func (m *mockMetadataService) GetFileRecord(ctx context.Context, fileID string) (FileRecord, error) {
+	return FileRecord{}, NewMetadataError("get", fileID, ErrFileNotFound)
+}
+
+func (m *mockMetadataService) DeleteFileRecord(ctx context.Context, fileID string) error {
+	return NewMetadataError("delete", fileID, ErrFileNotFound)
+}
+
+func (m *mockMetadataService) ListFileRecords(ctx context.Context, userID string, page PageRequest) (FileRecordPage, error) {
+	return FileRecordPage{}, nil
+}
+
+func (m *mockMetadataService) ListRecordsByStatus(ctx context.Context, status FileStatus, page PageRequest) (FileRecordPage, error) {
+	return FileRecordPage{}, nil
+}
+
 type mockStorageService struct {
-	err error
+	err         error
+	checksumErr error
+	stored      []byte
+	deleted     []string
 }
 
 func (m *mockStorageService) UploadFile(ctx context.Context, bucket, key string, data []byte) error {
-	return m.err
+	if m.err != nil {
+		return m.err
+	}
+	if m.stored == nil {
+		m.stored = data
+	}
+	return nil
+}
+
+func (m *mockStorageService) ObjectChecksum(ctx context.Context, bucket, key string, alg ChecksumAlgorithm) (Checksum, error) {
+	if m.checksumErr != nil {
+		return Checksum{}, m.checksumErr
+	}
+	return ComputeChecksum(alg, m.stored)
+}
+
+func (m *mockStorageService) DownloadFile(ctx context.Context, bucket, key string, rng *ByteRange) (io.ReadCloser, error) {
+	if m.stored == nil {
+		return nil, NewStorageError("download", bucket, key, ErrObjectNotFound)
+	}
+	return io.NopCloser(bytes.NewReader(m.stored)), nil
+}
+
+func (m *mockStorageService) StatFile(ctx context.Context, bucket, key string) (ObjectInfo, error) {
+	if m.stored == nil {
+		return ObjectInfo{}, NewStorageError("stat", bucket, key, ErrObjectNotFound)
+	}
+	return ObjectInfo{Size: int64(len(m.stored))}, nil
+}
+
+func (m *mockStorageService) DeleteFile(ctx context.Context, bucket, key string) error {
+	m.deleted = append(m.deleted, key)
+	return nil
 }
 
 // ============================================================================
@@ -527,3 +583,230 @@ func TestCloudStorageGateway_UploadFile_MetadataFailure(t *testing.T) {
 		t.Error("IsTemporary should return true for deadlock error")
 	}
 }
+
+// ============================================================================
+// Concurrent Upload Tests
+// ============================================================================
+
+// syncMetadataService signals when CreateFileRecord starts and can wait for storage
+type syncMetadataService struct {
+	mockMetadataService
+	waitFor  <-chan struct{}
+	mu       sync.Mutex
+	statuses []FileStatus
+}
+
+func (m *syncMetadataService) CreateFileRecord(ctx context.Context, rec FileRecord) (string, error) {
+	if m.waitFor != nil {
+		select {
+		case <-m.waitFor:
+		case <-ctx.Done():
+			return "", &MetadataError{Op: "insert", Err: ctx.Err()}
+		}
+	}
+	return m.mockMetadataService.CreateFileRecord(ctx, rec)
+}
+
+func (m *syncMetadataService) UpdateFileStatus(ctx context.Context, fileID string, status FileStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.statuses = append(m.statuses, status)
+	return m.updateErr
+}
+
+// syncStorageService signals when UploadFile starts and can block until cancelled
+type syncStorageService struct {
+	mockStorageService
+	started chan struct{}
+	block   bool
+	keys    []string
+}
+
+func (m *syncStorageService) UploadFile(ctx context.Context, bucket, key string, data []byte) error {
+	m.keys = append(m.keys, key)
+	if m.started != nil {
+		close(m.started)
+	}
+	if m.block {
+		<-ctx.Done()
+		return &StorageError{Op: "upload", Bucket: bucket, Key: key, Err: ctx.Err()}
+	}
+	return m.mockStorageService.UploadFile(ctx, bucket, key, data)
+}
+
+func TestCloudStorageGateway_UploadFile_ConcurrentOverlapsSteps(t *testing.T) {
+	started := make(chan struct{})
+	storage := &syncStorageService{started: started}
+	// CreateFileRecord only completes once the upload has started, so a
+	// sequential implementation would deadlock here
+	metadata := &syncMetadataService{mockMetadataService: mockMetadataService{fileID: "file456"}, waitFor: started}
+
+	gateway := NewCloudStorageGateway(&mockAuthService{userID: "user123"}, metadata, storage, WithConcurrentUpload())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := gateway.UploadFile(ctx, FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     []byte("hello world"),
+		Key:      "client-key-1",
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(storage.keys) != 1 || storage.keys[0] != ClientObjectKey("user123", "client-key-1") {
+		t.Errorf("expected upload under the client key, got %v", storage.keys)
+	}
+	if !slices.Equal(metadata.statuses, []FileStatus{StatusUploading, StatusCompleted}) {
+		t.Errorf("expected uploading then completed, got %v", metadata.statuses)
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_ConcurrentMetadataFailureCancelsUpload(t *testing.T) {
+	metaErr := &MetadataError{Op: "insert", Err: ErrDatabaseDeadlock, isTemp: true}
+	storage := &syncStorageService{block: true}
+
+	gateway := NewCloudStorageGateway(
+		&mockAuthService{userID: "user123"},
+		&mockMetadataService{createErr: metaErr},
+		storage,
+		WithConcurrentUpload(),
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     []byte("hello world"),
+		Key:      "client-key-1",
+	})
+
+	var extractedErr *MetadataError
+	if !errors.As(err, &extractedErr) {
+		t.Fatalf("expected the MetadataError that caused the failure, got: %v", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Error("the cancelled upload must not mask the original failure")
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_ConcurrentStorageFailureMarksRecordFailed(t *testing.T) {
+	storageErr := &StorageError{Op: "upload", Bucket: "my-bucket", Key: "client-key-1", Err: ErrStorageUnavailable, isTemp: true}
+	metadata := &syncMetadataService{mockMetadataService: mockMetadataService{fileID: "file456"}}
+
+	gateway := NewCloudStorageGateway(
+		&mockAuthService{userID: "user123"},
+		metadata,
+		&mockStorageService{err: storageErr},
+		WithConcurrentUpload(),
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     []byte("hello world"),
+		Key:      "client-key-1",
+	})
+
+	if !errors.Is(err, ErrStorageUnavailable) {
+		t.Fatalf("expected storage failure, got: %v", err)
+	}
+	// CreateFileRecord may have been cancelled before finishing; if it did
+	// create the record, the record must end up failed
+	if n := len(metadata.statuses); n > 0 && metadata.statuses[n-1] != StatusFailed {
+		t.Errorf("expected the record to end failed, got %v", metadata.statuses)
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_ConcurrentMetadataFailureDeletesOrphan(t *testing.T) {
+	started := make(chan struct{})
+	storage := &syncStorageService{started: started}
+	metadata := &syncMetadataService{
+		mockMetadataService: mockMetadataService{createErr: &MetadataError{Op: "insert", Err: errors.New("constraint violation")}},
+		waitFor:             started,
+	}
+
+	gateway := NewCloudStorageGateway(&mockAuthService{userID: "user123"}, metadata, storage, WithConcurrentUpload())
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     []byte("hello world"),
+		Key:      "client-key-1",
+	})
+
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if len(storage.deleted) != 1 || storage.deleted[0] != ClientObjectKey("user123", "client-key-1") {
+		t.Errorf("an object without a record should be deleted, got %v", storage.deleted)
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_ConcurrentStatusFailureDeletesObject(t *testing.T) {
+	started := make(chan struct{})
+	storage := &syncStorageService{started: started}
+	metadata := &syncMetadataService{
+		mockMetadataService: mockMetadataService{fileID: "file456", updateErr: &MetadataError{Op: "update", Err: ErrDatabaseDeadlock}},
+		waitFor:             started,
+	}
+
+	gateway := NewCloudStorageGateway(&mockAuthService{userID: "user123"}, metadata, storage, WithConcurrentUpload())
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     []byte("hello world"),
+		Key:      "client-key-1",
+	})
+
+	if !errors.Is(err, ErrDatabaseDeadlock) {
+		t.Fatalf("expected the status update failure, got: %v", err)
+	}
+	if len(storage.deleted) != 1 || storage.deleted[0] != ClientObjectKey("user123", "client-key-1") {
+		t.Errorf("the object of a record marked failed should be deleted, got %v", storage.deleted)
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_ConcurrentWithoutKeyIsSequential(t *testing.T) {
+	storage := &syncStorageService{}
+
+	gateway := NewCloudStorageGateway(
+		&mockAuthService{userID: "user123"},
+		&mockMetadataService{fileID: "file456"},
+		storage,
+		WithConcurrentUpload(),
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     []byte("hello world"),
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(storage.keys) != 1 || storage.keys[0] != "file456" {
+		t.Errorf("expected upload under the file ID, got %v", storage.keys)
+	}
+}
+
+func TestCloudStorageGateway_UploadFileResult(t *testing.T) {
+	gateway := NewCloudStorageGateway(&mockAuthService{userID: "user123"}, &mockMetadataService{fileID: "file456"}, &mockStorageService{})
+
+	res, err := gateway.UploadFileResult(context.Background(), FileUploadRequest{Token: "valid-token", FileName: "dir/test.txt", Bucket: "my-bucket", Data: []byte("hello")})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if res.FileID != "file456" || res.Key != "file456" || res.FileName != "test.txt" {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}
@@ -0,0 +1,124 @@
+package propagator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ============================================================================
+// Quota Service
+// ============================================================================
+
+// QuotaService tracks per-bucket storage usage
+// The gateway consults it before any metadata is written so over-quota
+// uploads are rejected without leaving records behind
+type QuotaService interface {
+	// Reserve atomically checks that size more bytes fit in bucket and holds them
+	// Returns StorageQuotaError when the bucket would exceed its limit
+	Reserve(ctx context.Context, bucket string, size int64) (reservationID string, err error)
+
+	// Commit turns a reservation into permanent usage
+	Commit(ctx context.Context, reservationID string) error
+
+	// Release returns the reserved bytes to the bucket
+	Release(ctx context.Context, reservationID string) error
+
+	// Free gives back size bytes of committed usage, e.g. when a stored file goes away
+	Free(ctx context.Context, bucket string, size int64) error
+}
+
+// ErrUnknownReservation is returned when committing or releasing a reservation that does not exist
+var ErrUnknownReservation = NewSentinel(CodeUnknownReservation, "unknown quota reservation")
+
+// InMemoryQuotaService is a QuotaService backed by in-process counters
+// Buckets without a configured limit are unlimited
+type InMemoryQuotaService struct {
+	mu           sync.Mutex
+	limits       map[string]int64
+	usage        map[string]int64
+	reservations map[string]reservation
+	nextID       int64
+}
+
+type reservation struct {
+	bucket string
+	size   int64
+}
+
+// NewInMemoryQuotaService creates a quota service enforcing the given per-bucket limits
+func NewInMemoryQuotaService(limits map[string]int64) *InMemoryQuotaService {
+	l := make(map[string]int64, len(limits))
+	for bucket, limit := range limits {
+		l[bucket] = limit
+	}
+	return &InMemoryQuotaService{
+		limits:       l,
+		usage:        make(map[string]int64),
+		reservations: make(map[string]reservation),
+	}
+}
+
+// Reserve holds size bytes in bucket if they fit under its limit
+func (q *InMemoryQuotaService) Reserve(ctx context.Context, bucket string, size int64) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	current := q.usage[bucket]
+	if limit, ok := q.limits[bucket]; ok && current+size > limit {
+		return "", NewStorageQuotaError(bucket, current, limit)
+	}
+
+	// Reserved bytes count towards usage until released
+	q.usage[bucket] = current + size
+	q.nextID++
+	id := fmt.Sprintf("rsv-%d", q.nextID)
+	q.reservations[id] = reservation{bucket: bucket, size: size}
+	return id, nil
+}
+
+// Commit keeps the reserved bytes as permanent usage
+func (q *InMemoryQuotaService) Commit(ctx context.Context, reservationID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.reservations[reservationID]; !ok {
+		return fmt.Errorf("commit %s: %w", reservationID, ErrUnknownReservation)
+	}
+	delete(q.reservations, reservationID)
+	return nil
+}
+
+// Release gives the reserved bytes back to the bucket
+func (q *InMemoryQuotaService) Release(ctx context.Context, reservationID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	r, ok := q.reservations[reservationID]
+	if !ok {
+		return fmt.Errorf("release %s: %w", reservationID, ErrUnknownReservation)
+	}
+	delete(q.reservations, reservationID)
+	q.usage[r.bucket] -= r.size
+	return nil
+}
+
+// Free lowers bucket's committed usage by size, never below zero
+func (q *InMemoryQuotaService) Free(ctx context.Context, bucket string, size int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.usage[bucket] = max(q.usage[bucket]-size, 0)
+	return nil
+}
+
+// Usage returns the bytes currently used or reserved in bucket
+func (q *InMemoryQuotaService) Usage(bucket string) int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.usage[bucket]
+}
@@ -0,0 +1,269 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// countingMetadataService records how often a file record is created
+type countingMetadataService struct {
+	mockMetadataService
+	creates int
+}
+
+func (m *countingMetadataService) CreateFileRecord(ctx context.Context, rec FileRecord) (string, error) {
+	m.creates++
+	return m.mockMetadataService.CreateFileRecord(ctx, rec)
+}
+
+// completionFailingMetadataService fails only the final move to completed
+type completionFailingMetadataService struct {
+	mockMetadataService
+}
+
+func (m *completionFailingMetadataService) UpdateFileStatus(ctx context.Context, fileID string, status FileStatus) error {
+	if status == StatusCompleted {
+		return NewMetadataError("update", fileID, ErrDatabaseDeadlock, AsTemporary())
+	}
+	return nil
+}
+
+// commitFailingQuotaService refuses every Commit
+type commitFailingQuotaService struct {
+	*InMemoryQuotaService
+}
+
+func (q commitFailingQuotaService) Commit(ctx context.Context, reservationID string) error {
+	return errors.New("quota backend unavailable")
+}
+
+// ============================================================================
+// Quota Service Tests
+// ============================================================================
+
+func TestInMemoryQuotaService_ReserveRejectsOverLimit(t *testing.T) {
+	q := NewInMemoryQuotaService(map[string]int64{"bucket": 10})
+	ctx := context.Background()
+
+	if _, err := q.Reserve(ctx, "bucket", 8); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err := q.Reserve(ctx, "bucket", 5)
+	var quotaErr *StorageQuotaError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("expected StorageQuotaError, got: %v", err)
+	}
+	if quotaErr.CurrentUsage != 8 || quotaErr.Limit != 10 {
+		t.Errorf("expected usage 8 / limit 10, got %d / %d", quotaErr.CurrentUsage, quotaErr.Limit)
+	}
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Error("errors.Is should find ErrQuotaExceeded")
+	}
+}
+
+func TestInMemoryQuotaService_ReleaseAndCommit(t *testing.T) {
+	q := NewInMemoryQuotaService(map[string]int64{"bucket": 10})
+	ctx := context.Background()
+
+	released, _ := q.Reserve(ctx, "bucket", 6)
+	if err := q.Release(ctx, released); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := q.Usage("bucket"); got != 0 {
+		t.Errorf("expected usage 0 after release, got %d", got)
+	}
+
+	committed, _ := q.Reserve(ctx, "bucket", 6)
+	if err := q.Commit(ctx, committed); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := q.Usage("bucket"); got != 6 {
+		t.Errorf("expected usage 6 after commit, got %d", got)
+	}
+
+	if err := q.Release(ctx, committed); !errors.Is(err, ErrUnknownReservation) {
+		t.Errorf("releasing a committed reservation should fail with ErrUnknownReservation, got: %v", err)
+	}
+}
+
+func TestInMemoryQuotaService_Free(t *testing.T) {
+	q := NewInMemoryQuotaService(map[string]int64{"bucket": 10})
+	ctx := context.Background()
+
+	id, _ := q.Reserve(ctx, "bucket", 6)
+	_ = q.Commit(ctx, id)
+	if err := q.Free(ctx, "bucket", 4); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := q.Usage("bucket"); got != 2 {
+		t.Errorf("expected usage 2 after freeing 4 bytes, got %d", got)
+	}
+
+	_ = q.Free(ctx, "bucket", 5)
+	if got := q.Usage("bucket"); got != 0 {
+		t.Errorf("usage must not go below zero, got %d", got)
+	}
+}
+
+func TestInMemoryQuotaService_ConcurrentReservations(t *testing.T) {
+	q := NewInMemoryQuotaService(map[string]int64{"bucket": 10})
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		accepted int
+	)
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := q.Reserve(context.Background(), "bucket", 1); err == nil {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accepted != 10 {
+		t.Errorf("expected exactly 10 reservations to fit, got %d", accepted)
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_QuotaRejectedBeforeMetadata(t *testing.T) {
+	metadata := &countingMetadataService{mockMetadataService: mockMetadataService{fileID: "file456"}}
+	quota := NewInMemoryQuotaService(map[string]int64{"my-bucket": 5})
+
+	gateway := NewCloudStorageGateway(
+		&mockAuthService{userID: "user123"},
+		metadata,
+		&mockStorageService{},
+		WithQuotaService(quota),
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     []byte("hello world"),
+	})
+
+	var quotaErr *StorageQuotaError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("expected StorageQuotaError, got: %v", err)
+	}
+	if quotaErr.Limit != 5 {
+		t.Errorf("expected Limit=5, got %d", quotaErr.Limit)
+	}
+	if metadata.creates != 0 {
+		t.Error("metadata must not be written for over-quota uploads")
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_QuotaReleasedOnFailure(t *testing.T) {
+	quota := NewInMemoryQuotaService(map[string]int64{"my-bucket": 100})
+
+	gateway := NewCloudStorageGateway(
+		&mockAuthService{userID: "user123"},
+		&mockMetadataService{fileID: "file456"},
+		&mockStorageService{err: &StorageError{Op: "upload", Err: ErrStorageUnavailable, isTemp: true}},
+		WithQuotaService(quota),
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     []byte("hello world"),
+	})
+
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if got := quota.Usage("my-bucket"); got != 0 {
+		t.Errorf("expected reservation to be released, usage is %d", got)
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_QuotaCommittedOnSuccess(t *testing.T) {
+	quota := NewInMemoryQuotaService(map[string]int64{"my-bucket": 100})
+
+	gateway := NewCloudStorageGateway(
+		&mockAuthService{userID: "user123"},
+		&mockMetadataService{fileID: "file456"},
+		&mockStorageService{},
+		WithQuotaService(quota),
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     []byte("hello world"),
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got := quota.Usage("my-bucket"); got != int64(len("hello world")) {
+		t.Errorf("expected committed usage %d, got %d", len("hello world"), got)
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_QuotaFreedWhenCompletionFails(t *testing.T) {
+	quota := NewInMemoryQuotaService(map[string]int64{"my-bucket": 100})
+
+	gateway := NewCloudStorageGateway(
+		&mockAuthService{userID: "user123"},
+		&completionFailingMetadataService{mockMetadataService: mockMetadataService{fileID: "file456"}},
+		&mockStorageService{},
+		WithQuotaService(quota),
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     []byte("hello world"),
+	})
+
+	if !errors.Is(err, ErrDatabaseDeadlock) {
+		t.Fatalf("expected the status update failure, got: %v", err)
+	}
+	if got := quota.Usage("my-bucket"); got != 0 {
+		t.Errorf("committed usage should be freed when the file never completes, usage is %d", got)
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_QuotaCommitFailureDeletesObject(t *testing.T) {
+	quota := NewInMemoryQuotaService(map[string]int64{"my-bucket": 100})
+	storage := &mockStorageService{}
+
+	gateway := NewCloudStorageGateway(
+		&mockAuthService{userID: "user123"},
+		&mockMetadataService{fileID: "file456"},
+		storage,
+		WithQuotaService(commitFailingQuotaService{quota}),
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     []byte("hello world"),
+	})
+
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if len(storage.deleted) != 1 || storage.deleted[0] != "file456" {
+		t.Errorf("the uncommitted object should be deleted, got %v", storage.deleted)
+	}
+	if got := quota.Usage("my-bucket"); got != 0 {
+		t.Errorf("expected the reservation to be released, usage is %d", got)
+	}
+}
@@ -0,0 +1,245 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// ============================================================================
+// Reconciler
+// ============================================================================
+
+// Defaults applied by NewReconciler
+const (
+	DefaultReconcileInterval = time.Minute
+	DefaultStaleAfter        = 15 * time.Minute
+)
+
+// ErrUploadAbandoned is the cause reported for uploads the Reconciler marks failed
+var ErrUploadAbandoned = NewSentinel(CodeUploadAbandoned, "upload abandoned before it finished")
+
+// ReconcileReport summarizes one reconciliation pass
+type ReconcileReport struct {
+	Scanned        int // Stale records examined
+	MarkedFailed   int // Records moved to StatusFailed
+	ObjectsDeleted int // Partial objects removed
+	Errors         int // Records left for the next pass
+}
+
+// Reconciler cleans up after uploads that never finished
+// An upload that crashes between CreateFileRecord and its final status update
+// leaves its record pending or uploading forever. The Reconciler finds such
+// records once they are older than the stale threshold, removes whatever
+// object was written for them, and marks them failed so the owner can see
+// and delete them. A record keeps its key until it is removed, so a retry
+// cannot claim a stale record's key (CreateFileRecord refuses it with
+// ErrKeyInUse) and the object under that key belongs to the stale record. If
+// a slow upload is still running when its record is reconciled, its final
+// status update is refused by the state machine and the upload fails instead
+// of resurrecting the record
+// Quota is left alone: a stale record does not say whether its upload
+// committed its reservation, so an upload that crashed between
+// QuotaService.Commit and its completed status leaks those bytes. Give them
+// back with QuotaService.Free when such a crash is known
+// Records it marks failed produce an EventUploadFailed with the code of
+// ErrUploadAbandoned, announced like the gateway's own (see WithReconcileOutbox)
+type Reconciler struct {
+	metadata   MetadataService
+	storage    StorageService
+	publisher  Publisher
+	outbox     Outbox
+	logger     *slog.Logger
+	interval   time.Duration
+	staleAfter time.Duration
+	now        func() time.Time
+}
+
+// ReconcilerOption configures a Reconciler
+type ReconcilerOption func(*Reconciler)
+
+// WithReconcileInterval sets how often Run starts a pass
+func WithReconcileInterval(d time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.interval = d
+	}
+}
+
+// WithStaleAfter sets how old a pending or uploading record must be before it is reconciled
+// It should comfortably exceed the longest expected upload
+func WithStaleAfter(d time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.staleAfter = d
+	}
+}
+
+// WithReconcilePublisher publishes an event for each record marked failed
+// As with WithPublisher, publishing is best effort
+func WithReconcilePublisher(publisher Publisher) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.publisher = publisher
+	}
+}
+
+// WithReconcileOutbox records an event with each record marked failed
+// Use the gateway's Outbox so its OutboxDispatcher delivers these events too
+func WithReconcileOutbox(outbox Outbox) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.outbox = outbox
+	}
+}
+
+// WithReconcileLogger sets the logger that reports actions; slog.Default() is used otherwise
+func WithReconcileLogger(logger *slog.Logger) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.logger = logger
+	}
+}
+
+// WithReconcileClock replaces time.Now when judging staleness, for tests
+func WithReconcileClock(now func() time.Time) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.now = now
+	}
+}
+
+// NewReconciler creates a Reconciler for the given services
+func NewReconciler(metadata MetadataService, storage StorageService, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		metadata:   metadata,
+		storage:    storage,
+		logger:     slog.Default(),
+		interval:   DefaultReconcileInterval,
+		staleAfter: DefaultStaleAfter,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run reconciles immediately and then on every interval until ctx is done
+// Passes never overlap. Failed passes are logged and retried on the next tick.
+// Returns ctx's error once it is done
+func (r *Reconciler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		_, _ = r.ReconcileOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReconcileOnce runs a single pass over the stale pending and uploading records
+// Records that cannot be reconciled are logged and skipped; their errors are
+// joined into the returned error. A failure to list records, or ctx being
+// done, ends the pass early
+func (r *Reconciler) ReconcileOnce(ctx context.Context) (ReconcileReport, error) {
+	var report ReconcileReport
+	var errs []error
+	cutoff := r.now().Add(-r.staleAfter)
+
+	for _, status := range []FileStatus{StatusPending, StatusUploading} {
+		page := PageRequest{Size: DefaultPageSize}
+		for {
+			if err := ctx.Err(); err != nil {
+				return report, errors.Join(append(errs, err)...)
+			}
+			result, err := r.metadata.ListRecordsByStatus(ctx, status, page)
+			if err != nil {
+				err = WrapWithContext(err, "reconcile failed: list records")
+				r.logFailure(ctx, "reconcile pass aborted", err)
+				return report, errors.Join(append(errs, err)...)
+			}
+			for _, rec := range result.Records {
+				if !rec.CreatedAt.Before(cutoff) {
+					continue
+				}
+				if err := ctx.Err(); err != nil {
+					return report, errors.Join(append(errs, err)...)
+				}
+				report.Scanned++
+				deleted, err := r.reconcile(ctx, rec)
+				if deleted {
+					report.ObjectsDeleted++
+				}
+				if err != nil {
+					report.Errors++
+					errs = append(errs, err)
+					r.logFailure(ctx, "reconcile failed", err, slog.String("file_id", rec.ID))
+					continue
+				}
+				report.MarkedFailed++
+			}
+			if result.NextToken == "" {
+				break
+			}
+			page.Token = result.NextToken
+		}
+	}
+
+	level := slog.LevelDebug
+	if report.Scanned > 0 {
+		level = slog.LevelInfo
+	}
+	r.logger.LogAttrs(ctx, level, "reconcile pass",
+		slog.Int("scanned", report.Scanned),
+		slog.Int("marked_failed", report.MarkedFailed),
+		slog.Int("objects_deleted", report.ObjectsDeleted),
+		slog.Int("errors", report.Errors),
+	)
+	return report, errors.Join(errs...)
+}
+
+// reconcile removes the object written for a stale record, if any, and marks the record failed
+// The record owns the object under its key, so nothing else is deleted
+// The object goes first: a record already marked failed is never scanned
+// again, so its object would be leaked if the deletion failed afterwards
+func (r *Reconciler) reconcile(ctx context.Context, rec FileRecord) (deleted bool, err error) {
+	key := rec.ObjectKey()
+	info, err := r.storage.StatFile(ctx, rec.Bucket, key)
+	switch {
+	case errors.Is(err, ErrObjectNotFound):
+	case err != nil:
+		return false, WrapWithContext(err, "reconcile failed: stat object")
+	default:
+		if err := r.storage.DeleteFile(ctx, rec.Bucket, key); err != nil && !errors.Is(err, ErrObjectNotFound) {
+			return false, WrapWithContext(err, "reconcile failed: delete object")
+		}
+		deleted = true
+		r.logger.LogAttrs(ctx, slog.LevelInfo, "reconcile deleted partial object",
+			slog.String("file_id", rec.ID),
+			slog.String("bucket", rec.Bucket),
+			slog.String("key", key),
+			slog.Int64("size", info.Size),
+		)
+	}
+
+	ev := newRecordEvent(EventUploadFailed, rec, ErrUploadAbandoned)
+	if err := updateStatusWithEvent(ctx, r.metadata, r.outbox, r.publisher, StatusFailed, ev); err != nil {
+		return deleted, WrapWithContext(err, "reconcile failed: status update")
+	}
+	r.logger.LogAttrs(ctx, slog.LevelInfo, "reconcile marked file failed",
+		slog.String("file_id", rec.ID),
+		slog.String("user_id", rec.UserID),
+		slog.String("from", string(rec.Status)),
+		slog.Time("created_at", rec.CreatedAt),
+	)
+	return deleted, nil
+}
+
+// logFailure logs err at Warn when it is temporary and at Error otherwise
+func (r *Reconciler) logFailure(ctx context.Context, msg string, err error, attrs ...slog.Attr) {
+	level := slog.LevelError
+	if IsTemporary(err) {
+		level = slog.LevelWarn
+	}
+	r.logger.LogAttrs(ctx, level, msg, append(attrs, slog.Any("error", err))...)
+}
@@ -0,0 +1,228 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// staleRecord creates a record in status, optionally with its object stored
+func staleRecord(t *testing.T, store *memFileStore, key string, status FileStatus, withObject bool) string {
+	t.Helper()
+	ctx := context.Background()
+	fileID, err := store.CreateFileRecord(ctx, FileRecord{UserID: "user-alice", FileName: "a.txt", Bucket: "my-bucket", Key: key, Size: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status == StatusUploading {
+		_ = store.UpdateFileStatus(ctx, fileID, StatusUploading)
+	}
+	if withObject {
+		if key == "" {
+			key = fileID
+		}
+		_ = store.UploadFile(ctx, "my-bucket", key, []byte("hello"))
+	}
+	return fileID
+}
+
+// inAnHour is a clock that makes every record created now look stale
+func inAnHour() time.Time {
+	return time.Now().Add(time.Hour)
+}
+
+// ============================================================================
+// Reconciler Tests
+// ============================================================================
+
+func TestReconciler_MarksStaleRecordsFailed(t *testing.T) {
+	store := newMemFileStore()
+	pending := staleRecord(t, store, "", StatusPending, false)
+	partial := staleRecord(t, store, "", StatusUploading, true)
+	clientKey := staleRecord(t, store, ClientObjectKey("user-alice", "client-key-1"), StatusUploading, true)
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store)
+	completed := uploadTestFile(t, gateway, "alice", []byte("hello"))
+
+	var buf strings.Builder
+	r := NewReconciler(store, store,
+		WithReconcileClock(inAnHour),
+		WithReconcileLogger(slog.New(slog.NewTextHandler(&buf, nil))),
+	)
+	report, err := r.ReconcileOnce(context.Background())
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if report != (ReconcileReport{Scanned: 3, MarkedFailed: 3, ObjectsDeleted: 2}) {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	for _, id := range []string{pending, partial, clientKey} {
+		if status := store.records[id].Status; status != StatusFailed {
+			t.Errorf("%s: status = %q, want failed", id, status)
+		}
+	}
+	if _, ok := store.objects["my-bucket/"+partial]; ok {
+		t.Error("the partial object should be deleted")
+	}
+	if _, ok := store.objects["my-bucket/"+ClientObjectKey("user-alice", "client-key-1")]; ok {
+		t.Error("the partial object under a client key should be deleted")
+	}
+	if store.records[completed].Status != StatusCompleted {
+		t.Error("completed records must not be touched")
+	}
+	for _, want := range []string{"msg=\"reconcile marked file failed\" file_id=" + partial, "msg=\"reconcile deleted partial object\"", "scanned=3"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestReconciler_RetryCannotClaimStaleKey(t *testing.T) {
+	store := newMemFileStore()
+	stale := staleRecord(t, store, ClientObjectKey("user-alice", "k"), StatusPending, false)
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store)
+
+	_, err := uploadWithKey(gateway, "alice", "k", []byte("retry"))
+	if !errors.Is(err, ErrKeyInUse) {
+		t.Fatalf("a retry must not claim the key of a crashed upload, got: %v", err)
+	}
+	if _, err := NewReconciler(store, store, WithReconcileClock(inAnHour)).ReconcileOnce(context.Background()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if err := gateway.DeleteFile(context.Background(), "alice", stale); err != nil {
+		t.Fatalf("the owner should be able to delete the failed record, got: %v", err)
+	}
+	if _, err := uploadWithKey(gateway, "alice", "k", []byte("retry")); err != nil {
+		t.Errorf("the key should be free after the delete, got: %v", err)
+	}
+	if got := string(store.objects["my-bucket/"+ClientObjectKey("user-alice", "k")]); got != "retry" {
+		t.Errorf("expected the retried content, got %q", got)
+	}
+}
+
+func TestReconciler_AnnouncesFailures(t *testing.T) {
+	store := newMemFileStore()
+	fileID := staleRecord(t, store, ClientObjectKey("user-alice", "k"), StatusUploading, true)
+	publisher := &recordingPublisher{}
+
+	r := NewReconciler(store, store, WithReconcileClock(inAnHour), WithReconcilePublisher(publisher))
+	if _, err := r.ReconcileOnce(context.Background()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(publisher.events) != 1 {
+		t.Fatalf("expected one published event, got %+v", publisher.events)
+	}
+	ev := publisher.events[0]
+	if ev.ID != fileID+"/upload.failed" || ev.Type != EventUploadFailed || ev.UserID != "user-alice" || ev.Key != ClientObjectKey("user-alice", "k") || ev.Size != 5 {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+	if ev.ErrorCode != CodeUploadAbandoned {
+		t.Errorf("expected %s, got %s", CodeUploadAbandoned, ev.ErrorCode)
+	}
+}
+
+func TestReconciler_RecordsFailuresInOutbox(t *testing.T) {
+	store := newMemFileStore()
+	fileID := staleRecord(t, store, "", StatusPending, false)
+	outbox := &sliceOutbox{}
+
+	r := NewReconciler(store, store, WithReconcileClock(inAnHour), WithReconcileOutbox(outbox))
+	if _, err := r.ReconcileOnce(context.Background()); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(outbox.events) != 1 || outbox.events[0].FileID != fileID || outbox.events[0].Type != EventUploadFailed {
+		t.Errorf("expected the failure in the outbox, got %+v", outbox.events)
+	}
+}
+
+func TestReconciler_SkipsFreshRecords(t *testing.T) {
+	store := newMemFileStore()
+	fileID := staleRecord(t, store, "", StatusUploading, true)
+
+	report, err := NewReconciler(store, store, WithStaleAfter(time.Hour)).ReconcileOnce(context.Background())
+
+	if err != nil || report.Scanned != 0 {
+		t.Errorf("fresh records should be skipped, got %+v, %v", report, err)
+	}
+	if store.records[fileID].Status != StatusUploading {
+		t.Error("a running upload must be left alone")
+	}
+}
+
+func TestReconciler_PagesThroughAllRecords(t *testing.T) {
+	store := newMemFileStore()
+	for range DefaultPageSize + 5 {
+		staleRecord(t, store, "", StatusPending, false)
+	}
+
+	report, err := NewReconciler(store, store, WithReconcileClock(inAnHour)).ReconcileOnce(context.Background())
+
+	if err != nil || report.MarkedFailed != DefaultPageSize+5 {
+		t.Errorf("expected every record to be reconciled, got %+v, %v", report, err)
+	}
+}
+
+func TestReconciler_StorageFailureLeavesRecordForNextPass(t *testing.T) {
+	store := newMemFileStore()
+	fileID := staleRecord(t, store, "", StatusUploading, true)
+	var failing atomic.Bool
+	failing.Store(true)
+	storage := InterceptStorage(store, func(ctx context.Context, call Call, invoke Invoker) error {
+		if call.Method == "StatFile" && failing.Load() {
+			return NewStorageError("stat", call.Bucket, call.Key, ErrStorageUnavailable, AsTemporary())
+		}
+		return invoke(ctx)
+	})
+
+	var buf strings.Builder
+	r := NewReconciler(store, storage,
+		WithReconcileClock(inAnHour),
+		WithReconcileLogger(slog.New(slog.NewTextHandler(&buf, nil))),
+	)
+	report, err := r.ReconcileOnce(context.Background())
+
+	if !errors.Is(err, ErrStorageUnavailable) || !IsTemporary(err) || report.Errors != 1 {
+		t.Fatalf("expected a temporary storage failure, got %+v, %v", report, err)
+	}
+	if store.records[fileID].Status != StatusUploading {
+		t.Error("the record should stay for the next pass")
+	}
+	if !strings.Contains(buf.String(), "level=WARN msg=\"reconcile failed\" file_id="+fileID) {
+		t.Errorf("expected the failure to be logged at WARN:\n%s", buf.String())
+	}
+
+	failing.Store(false)
+	if report, err := r.ReconcileOnce(context.Background()); err != nil || report.MarkedFailed != 1 {
+		t.Errorf("the next pass should finish the job, got %+v, %v", report, err)
+	}
+}
+
+func TestReconciler_RunStopsWithContext(t *testing.T) {
+	var passes atomic.Int32
+	metadata := InterceptMetadata(newMemFileStore(), func(ctx context.Context, call Call, invoke Invoker) error {
+		if call.Method == "ListRecordsByStatus" {
+			passes.Add(1)
+		}
+		return invoke(ctx)
+	})
+	r := NewReconciler(metadata, newMemFileStore(), WithReconcileInterval(5*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := r.Run(ctx)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context error, got: %v", err)
+	}
+	// Each pass lists pending and uploading records once
+	if n := passes.Load(); n < 4 {
+		t.Errorf("expected the ticker to start several passes, got %d list calls", n)
+	}
+}
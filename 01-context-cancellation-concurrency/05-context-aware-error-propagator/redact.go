@@ -0,0 +1,169 @@
+package propagator
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// ============================================================================
+// Redaction
+// ============================================================================
+
+// redactedValue replaces secrets in every rendering of an error
+const redactedValue = "[REDACTED]"
+
+// Redactor is implemented by values that know how to mask their own secrets
+// Redacted returns a copy that is safe to print or log
+type Redactor interface {
+	Redacted() any
+}
+
+// Redact returns a copy of v with every sensitive field masked
+// Values implementing Redactor decide for themselves; otherwise string and
+// []byte fields tagged `redact:"true"` are replaced with "[REDACTED]" when set
+// and any other tagged field is zeroed. Structs and pointers to structs are
+// supported; anything else is returned unchanged
+func Redact(v any) any {
+	if r, ok := v.(Redactor); ok {
+		return r.Redacted()
+	}
+
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Pointer && !rv.IsNil() && rv.Elem().Kind() == reflect.Struct:
+		cp := reflect.New(rv.Elem().Type())
+		cp.Elem().Set(rv.Elem())
+		redactFields(cp.Elem())
+		return cp.Interface()
+	case rv.Kind() == reflect.Struct:
+		cp := reflect.New(rv.Type()).Elem()
+		cp.Set(rv)
+		redactFields(cp)
+		return cp.Interface()
+	default:
+		return v
+	}
+}
+
+// redactFields masks the tagged fields of an addressable struct value in place
+func redactFields(rv reflect.Value) {
+	rt := rv.Type()
+	for i := range rt.NumField() {
+		field := rv.Field(i)
+		if rt.Field(i).Tag.Get("redact") != "true" || !field.CanSet() || field.IsZero() {
+			continue
+		}
+		switch {
+		case field.Kind() == reflect.String:
+			field.SetString(redactedValue)
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8:
+			field.SetBytes([]byte(redactedValue))
+		default:
+			field.Set(reflect.Zero(field.Type()))
+		}
+	}
+}
+
+// RedactAttr is a slog.HandlerOptions.ReplaceAttr function that passes every
+// logged value through Redact, so structs with tagged fields are safe to log
+// even when they do not implement slog.LogValuer
+func RedactAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindAny {
+		a.Value = slog.AnyValue(Redact(a.Value.Any()))
+	}
+	return a
+}
+
+// ============================================================================
+// Formatting
+// ============================================================================
+
+// formatError implements fmt.Formatter for the error types in this package
+//
+//	%s, %v  the Error() message
+//	%q      the quoted Error() message
+//	%+v     the whole chain, one error per line with its redacted fields
+//	        and the file:line where it was created when stacks are captured
+//	%#v     Go syntax with sensitive fields masked
+func formatError(s fmt.State, verb rune, err error) {
+	switch verb {
+	case 'v':
+		switch {
+		case s.Flag('#'):
+			writeGoSyntax(s, err)
+			return
+		case s.Flag('+'):
+			var b strings.Builder
+			writeChain(&b, err, 0)
+			io.WriteString(s, strings.TrimSuffix(b.String(), "\n"))
+			return
+		}
+		io.WriteString(s, err.Error())
+	case 's':
+		io.WriteString(s, err.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", err.Error())
+	default:
+		fmt.Fprintf(s, "%%!%c(%T=%s)", verb, err, err.Error())
+	}
+}
+
+// writeFields writes the redacted exported fields of err, except the wrapped error
+func writeFields(w io.Writer, err error) {
+	rv := reflect.Indirect(reflect.ValueOf(Redact(err)))
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+	rt := rv.Type()
+	var parts []string
+	for i := range rt.NumField() {
+		f := rt.Field(i)
+		if !f.IsExported() || f.Name == "Err" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%v", f.Name, rv.Field(i).Interface()))
+	}
+	if len(parts) > 0 {
+		fmt.Fprintf(w, " {%s}", strings.Join(parts, " "))
+	}
+}
+
+// writeGoSyntax writes err in Go syntax using its redacted copy
+// Only exported fields are shown; nested values are formatted with %#v so
+// wrapped errors from this package are redacted too
+func writeGoSyntax(w io.Writer, err error) {
+	red := Redact(err)
+	rv := reflect.ValueOf(red)
+	prefix := ""
+	if rv.Kind() == reflect.Pointer {
+		prefix = "&"
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		fmt.Fprintf(w, "%#v", red)
+		return
+	}
+	rt := rv.Type()
+	var parts []string
+	for i := range rt.NumField() {
+		f := rt.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s:%#v", f.Name, rv.Field(i).Interface()))
+	}
+	fmt.Fprintf(w, "%s%s{%s}", prefix, rt.String(), strings.Join(parts, ", "))
+}
+
+func (e *AuthError) Format(s fmt.State, verb rune)             { formatError(s, verb, e) }
+func (e *MetadataError) Format(s fmt.State, verb rune)         { formatError(s, verb, e) }
+func (e *StorageError) Format(s fmt.State, verb rune)          { formatError(s, verb, e) }
+func (e *StorageQuotaError) Format(s fmt.State, verb rune)     { formatError(s, verb, e) }
+func (e *IntegrityError) Format(s fmt.State, verb rune)        { formatError(s, verb, e) }
+func (e *PermissionError) Format(s fmt.State, verb rune)       { formatError(s, verb, e) }
+func (e *StatusTransitionError) Format(s fmt.State, verb rune) { formatError(s, verb, e) }
+func (e *ValidationError) Format(s fmt.State, verb rune)       { formatError(s, verb, e) }
+func (e *KeyProviderError) Format(s fmt.State, verb rune)      { formatError(s, verb, e) }
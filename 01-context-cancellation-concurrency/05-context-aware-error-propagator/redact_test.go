@@ -0,0 +1,150 @@
+package propagator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+const testSecret = "sk-super-secret-api-key-12345"
+
+// credentials is a caller-defined struct relying on struct tags only
+type credentials struct {
+	Username string
+	Password string `redact:"true"`
+	Token    []byte `redact:"true"`
+}
+
+// session implements Redactor itself
+type session struct {
+	ID     string
+	Cookie string
+}
+
+func (s session) Redacted() any {
+	return session{ID: s.ID, Cookie: "[REDACTED]"}
+}
+
+// ============================================================================
+// Redact Tests
+// ============================================================================
+
+func TestRedact_StructTags(t *testing.T) {
+	orig := &credentials{Username: "alice", Password: testSecret, Token: []byte(testSecret)}
+
+	got := Redact(orig).(*credentials)
+
+	if got.Password != "[REDACTED]" || string(got.Token) != "[REDACTED]" {
+		t.Errorf("tagged fields should be redacted, got %+v", got)
+	}
+	if got.Username != "alice" {
+		t.Error("untagged fields must be preserved")
+	}
+	if orig.Password != testSecret {
+		t.Error("Redact must not modify the original value")
+	}
+
+	empty := Redact(credentials{Username: "bob"}).(credentials)
+	if empty.Password != "" {
+		t.Error("empty secrets should stay empty so their absence is visible")
+	}
+}
+
+func TestRedact_RedactorInterface(t *testing.T) {
+	got := Redact(session{ID: "s1", Cookie: testSecret}).(session)
+	if got.Cookie != "[REDACTED]" || got.ID != "s1" {
+		t.Errorf("Redactor should be used, got %+v", got)
+	}
+}
+
+func TestAuthError_ErrorMentionsKeyOnlyWhenPresent(t *testing.T) {
+	withKey := &AuthError{Op: "validate_token", UserID: "user123", APIKey: testSecret, Err: ErrAuthFailed}
+	if !strings.Contains(withKey.Error(), "key=[REDACTED]") {
+		t.Errorf("expected key=[REDACTED] when a key is present, got: %s", withKey.Error())
+	}
+
+	withoutKey := &AuthError{Op: "validate_token", UserID: "user123", Err: ErrAuthFailed}
+	if strings.Contains(withoutKey.Error(), "REDACTED") {
+		t.Errorf("no redaction marker expected without a key, got: %s", withoutKey.Error())
+	}
+}
+
+// ============================================================================
+// Test: secrets never appear in any rendering
+// ============================================================================
+
+func TestAuthError_SecretsNeverRendered(t *testing.T) {
+	authErr := &AuthError{
+		Op:     "validate_token",
+		UserID: "user123",
+		APIKey: testSecret,
+		Err:    ErrAuthFailed,
+	}
+
+	errs := map[string]error{
+		"direct":  authErr,
+		"wrapped": WrapWithContext(fmt.Errorf("middle: %w", authErr), "upload failed: auth"),
+		"joined":  errors.Join(errors.New("other failure"), authErr),
+		"nested":  &AuthError{Op: "refresh_token", APIKey: testSecret, Err: authErr},
+	}
+	verbs := []string{"%v", "%s", "%q", "%+v", "%#v"}
+
+	for name, err := range errs {
+		for _, verb := range verbs {
+			t.Run(name+" "+verb, func(t *testing.T) {
+				if out := fmt.Sprintf(verb, err); strings.Contains(out, testSecret) {
+					t.Errorf("SENSITIVE DATA LEAK via %s:\n%s", verb, out)
+				}
+			})
+		}
+
+		t.Run(name+" Error()", func(t *testing.T) {
+			if strings.Contains(err.Error(), testSecret) {
+				t.Errorf("SENSITIVE DATA LEAK via Error():\n%s", err.Error())
+			}
+		})
+
+		t.Run(name+" slog", func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := &slog.HandlerOptions{ReplaceAttr: RedactAttr}
+			logger := slog.New(slog.NewJSONHandler(&buf, opts))
+			logger.Error("failed", "err", err, ErrorChain("chain", err))
+			slog.New(slog.NewTextHandler(&buf, nil)).Error("failed", "err", err, ErrorChain("chain", err))
+
+			if strings.Contains(buf.String(), testSecret) {
+				t.Errorf("SENSITIVE DATA LEAK via slog:\n%s", buf.String())
+			}
+		})
+	}
+}
+
+func TestAuthError_FormatVerbs(t *testing.T) {
+	authErr := &AuthError{Op: "validate_token", UserID: "user123", APIKey: testSecret, Err: ErrAuthFailed}
+
+	if got := fmt.Sprintf("%v", authErr); got != authErr.Error() {
+		t.Errorf("%%v should match Error(), got %q", got)
+	}
+	if got := fmt.Sprintf("%+v", authErr); !strings.Contains(got, "APIKey=[REDACTED]") || !strings.Contains(got, "UserID=user123") {
+		t.Errorf("%%+v should list redacted fields, got %q", got)
+	}
+	if got := fmt.Sprintf("%#v", authErr); !strings.HasPrefix(got, "&propagator.AuthError{") || !strings.Contains(got, `APIKey:"[REDACTED]"`) {
+		t.Errorf("%%#v should render redacted Go syntax, got %q", got)
+	}
+}
+
+func TestRedactAttr_TaggedStructs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{ReplaceAttr: RedactAttr}))
+
+	logger.Info("login", "creds", credentials{Username: "alice", Password: testSecret})
+
+	if strings.Contains(buf.String(), testSecret) {
+		t.Errorf("SENSITIVE DATA LEAK via RedactAttr:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "alice") {
+		t.Errorf("non-sensitive fields should still be logged:\n%s", buf.String())
+	}
+}
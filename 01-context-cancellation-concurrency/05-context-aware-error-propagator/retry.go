@@ -0,0 +1,94 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ============================================================================
+// Retry Hints
+// ============================================================================
+
+// retryAfterError is implemented by errors carrying a backend retry hint
+type retryAfterError interface {
+	RetryAfter() time.Duration
+}
+
+// RetryAfterHint returns the strongest (longest) retry hint in err's tree
+// Both wrapped chains and errors.Join branches are searched
+// The boolean is false when no error in the tree carries a positive hint
+func RetryAfterHint(err error) (time.Duration, bool) {
+	var best time.Duration
+	walkChain(err, func(e error) {
+		if ra, ok := e.(retryAfterError); ok && ra.RetryAfter() > best {
+			best = ra.RetryAfter()
+		}
+	})
+	return best, best > 0
+}
+
+// ============================================================================
+// Retry Loop
+// ============================================================================
+
+// RetryPolicy configures Retry
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first; values below 1 mean 1
+	BaseDelay   time.Duration // Delay before the second attempt, doubled for each further attempt
+	MaxDelay    time.Duration // Upper bound for the computed backoff; zero means no bound
+}
+
+// DefaultRetryPolicy is a conservative policy for gateway calls
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   100 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+}
+
+// backoff returns the exponential delay before attempt n+1 (n starts at 1)
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return d
+}
+
+// Retry calls fn until it succeeds, returns a non-temporary error, or the
+// attempts are exhausted
+// Between attempts it waits for the exponential backoff or the error's
+// RetryAfterHint, whichever is longer: a backend that asks for a pause is
+// never hammered sooner. If the wait would outlast ctx's deadline, Retry
+// gives up immediately and returns the last error
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	attempts := max(policy.MaxAttempts, 1)
+	for n := 1; ; n++ {
+		err := fn(ctx)
+		if err == nil || !IsTemporary(err) || n >= attempts {
+			return err
+		}
+
+		delay := policy.backoff(n)
+		if hint, ok := RetryAfterHint(err); ok && hint > delay {
+			delay = hint
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
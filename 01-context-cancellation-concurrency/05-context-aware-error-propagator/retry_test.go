@@ -0,0 +1,185 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// ============================================================================
+// Retry Hint Tests
+// ============================================================================
+
+func TestRetryAfterHint(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		want   time.Duration
+		wantOK bool
+	}{
+		{
+			name: "nil error",
+			err:  nil,
+		},
+		{
+			name: "no hint",
+			err:  NewStorageError("upload", "b", "k", ErrStorageUnavailable, AsTemporary()),
+		},
+		{
+			name:   "wrapped metadata hint",
+			err:    WrapWithContext(NewMetadataError("insert", "f", ErrDatabaseDeadlock, WithRetryAfter(50*time.Millisecond)), "create file record failed"),
+			want:   50 * time.Millisecond,
+			wantOK: true,
+		},
+		{
+			name: "strongest hint across joined branches",
+			err: errors.Join(
+				NewMetadataError("update", "f1", ErrDatabaseDeadlock, WithRetryAfter(time.Second)),
+				WrapWithContext(NewStorageError("upload", "b", "k", ErrStorageUnavailable, WithRetryAfter(3*time.Second)), "throttled"),
+			),
+			want:   3 * time.Second,
+			wantOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RetryAfterHint(tt.err)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("RetryAfterHint() = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestWithRetryAfter_MarksTemporary(t *testing.T) {
+	err := NewStorageError("upload", "b", "k", ErrStorageUnavailable, WithRetryAfter(time.Second))
+	if !IsTemporary(err) {
+		t.Error("an error with a retry hint should be temporary")
+	}
+	if err.RetryAfter() != time.Second {
+		t.Errorf("RetryAfter() = %v, want 1s", err.RetryAfter())
+	}
+}
+
+// ============================================================================
+// Retry Loop Tests
+// ============================================================================
+
+func TestRetry_HonorsRetryAfterHint(t *testing.T) {
+	hint := 40 * time.Millisecond
+	policy := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}
+
+	calls := 0
+	start := time.Now()
+	err := Retry(context.Background(), policy, func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			return NewStorageError("upload", "b", "k", ErrStorageUnavailable, WithRetryAfter(hint))
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("expected success on retry, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < hint {
+		t.Errorf("retry should wait at least the hint (%v), waited %v", hint, elapsed)
+	}
+}
+
+func TestRetry_StopsOnPermanentError(t *testing.T) {
+	calls := 0
+	err := Retry(context.Background(), DefaultRetryPolicy, func(ctx context.Context) error {
+		calls++
+		return &AuthError{Op: "validate_token", Err: ErrInvalidToken}
+	})
+
+	if calls != 1 {
+		t.Errorf("permanent errors must not be retried, got %d calls", calls)
+	}
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected the permanent error back, got: %v", err)
+	}
+}
+
+func TestRetry_ExhaustsAttempts(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+	calls := 0
+	err := Retry(context.Background(), policy, func(ctx context.Context) error {
+		calls++
+		return NewMetadataError("insert", "f", ErrDatabaseDeadlock, AsTemporary())
+	})
+
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+	if !errors.Is(err, ErrDatabaseDeadlock) {
+		t.Errorf("expected last error back, got: %v", err)
+	}
+}
+
+func TestRetry_GivesUpWhenHintExceedsDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	start := time.Now()
+	err := Retry(ctx, DefaultRetryPolicy, func(ctx context.Context) error {
+		calls++
+		return NewStorageError("upload", "b", "k", ErrStorageUnavailable, WithRetryAfter(time.Minute))
+	})
+
+	if calls != 1 {
+		t.Errorf("expected a single attempt, got %d", calls)
+	}
+	if time.Since(start) > 40*time.Millisecond {
+		t.Error("Retry should not sleep when the hint cannot fit in the deadline")
+	}
+	if _, ok := RetryAfterHint(err); !ok {
+		t.Error("the returned error should still carry the hint for the caller")
+	}
+}
+
+func TestRetry_UploadFileThroughGateway(t *testing.T) {
+	storage := &flakyStorageService{failures: 1, hint: 10 * time.Millisecond}
+	gateway := NewCloudStorageGateway(
+		&mockAuthService{userID: "user123"},
+		&mockMetadataService{fileID: "file456"},
+		storage,
+	)
+
+	err := Retry(context.Background(), RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}, func(ctx context.Context) error {
+		return gateway.UploadFile(ctx, FileUploadRequest{
+			Token:    "valid-token",
+			FileName: "test.txt",
+			Bucket:   "my-bucket",
+			Data:     []byte("hello world"),
+		})
+	})
+
+	if err != nil {
+		t.Fatalf("expected the retried upload to succeed, got: %v", err)
+	}
+	if storage.calls != 2 {
+		t.Errorf("expected 2 storage calls, got %d", storage.calls)
+	}
+}
+
+// flakyStorageService fails the first uploads with a throttling hint
+type flakyStorageService struct {
+	mockStorageService
+	failures int
+	hint     time.Duration
+	calls    int
+}
+
+func (m *flakyStorageService) UploadFile(ctx context.Context, bucket, key string, data []byte) error {
+	m.calls++
+	if m.calls <= m.failures {
+		return NewStorageError("upload", bucket, key, ErrStorageUnavailable, WithRetryAfter(m.hint))
+	}
+	return m.mockStorageService.UploadFile(ctx, bucket, key, data)
+}
@@ -0,0 +1,332 @@
+package propagator
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ============================================================================
+// Stack Capture
+// ============================================================================
+
+// maxStackDepth bounds the number of frames recorded per error
+const maxStackDepth = 32
+
+// captureStacks toggles stack capture at error creation
+// It is off by default; building with -tags propagator_stacks turns it on
+var captureStacks atomic.Bool
+
+// SetStackCapture enables or disables recording a caller stack when errors
+// are created through the constructors in this package or WrapWithContext
+// Capturing costs a runtime.Callers call per error, so keep it off on hot paths
+func SetStackCapture(enabled bool) {
+	captureStacks.Store(enabled)
+}
+
+// StackCaptureEnabled reports whether new errors record their caller stack
+func StackCaptureEnabled() bool {
+	return captureStacks.Load()
+}
+
+// StackTracer is implemented by errors that recorded where they were created
+type StackTracer interface {
+	StackTrace() []runtime.Frame
+}
+
+// stack is a list of program counters captured at error creation
+type stack []uintptr
+
+// captureStack records the stack of the function calling the constructor
+// It returns nil when capture is disabled
+func captureStack() stack {
+	if !captureStacks.Load() {
+		return nil
+	}
+	// Skip runtime.Callers, captureStack and the constructor itself
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(3, pcs)
+	return stack(pcs[:n])
+}
+
+// frames resolves the program counters into frames
+func (s stack) frames() []runtime.Frame {
+	if len(s) == 0 {
+		return nil
+	}
+	var out []runtime.Frame
+	it := runtime.CallersFrames(s)
+	for {
+		frame, more := it.Next()
+		out = append(out, frame)
+		if !more {
+			break
+		}
+	}
+	return out
+}
+
+// location renders the creation site as "function (file:line)", or "" if no stack was captured
+func (s stack) location() string {
+	frames := s.frames()
+	if len(frames) == 0 {
+		return ""
+	}
+	fn := frames[0].Function
+	if i := strings.LastIndex(fn, "/"); i >= 0 {
+		fn = fn[i+1:]
+	}
+	return fmt.Sprintf("%s (%s:%d)", fn, filepath.Base(frames[0].File), frames[0].Line)
+}
+
+func (e *AuthError) StackTrace() []runtime.Frame             { return e.stack.frames() }
+func (e *MetadataError) StackTrace() []runtime.Frame         { return e.stack.frames() }
+func (e *StorageError) StackTrace() []runtime.Frame          { return e.stack.frames() }
+func (e *StorageQuotaError) StackTrace() []runtime.Frame     { return e.stack.frames() }
+func (e *IntegrityError) StackTrace() []runtime.Frame        { return e.stack.frames() }
+func (e *PermissionError) StackTrace() []runtime.Frame       { return e.stack.frames() }
+func (e *StatusTransitionError) StackTrace() []runtime.Frame { return e.stack.frames() }
+func (e *ValidationError) StackTrace() []runtime.Frame       { return e.stack.frames() }
+func (e *KeyProviderError) StackTrace() []runtime.Frame      { return e.stack.frames() }
+
+// ============================================================================
+// Constructors
+// ============================================================================
+
+// ErrorOption sets classification flags on errors built by the constructors
+type ErrorOption func(*errorFlags)
+
+type errorFlags struct {
+	timeout    bool
+	temporary  bool
+	retryAfter time.Duration
+}
+
+// AsTimeout marks the error as a timeout
+// MetadataError has no timeout flag and ignores it
+func AsTimeout() ErrorOption {
+	return func(f *errorFlags) { f.timeout = true }
+}
+
+// AsTemporary marks the error as temporary/retriable
+func AsTemporary() ErrorOption {
+	return func(f *errorFlags) { f.temporary = true }
+}
+
+// WithRetryAfter attaches a backend-provided retry hint and marks the error temporary
+// Only MetadataError and StorageError carry the hint
+func WithRetryAfter(d time.Duration) ErrorOption {
+	return func(f *errorFlags) {
+		f.retryAfter = d
+		f.temporary = true
+	}
+}
+
+func applyErrorOptions(opts []ErrorOption) errorFlags {
+	var f errorFlags
+	for _, opt := range opts {
+		opt(&f)
+	}
+	return f
+}
+
+// NewAuthError creates an AuthError, capturing the caller stack when enabled
+func NewAuthError(op, userID, apiKey string, err error, opts ...ErrorOption) *AuthError {
+	f := applyErrorOptions(opts)
+	return &AuthError{
+		Op:        op,
+		UserID:    userID,
+		APIKey:    apiKey,
+		Err:       err,
+		isTimeout: f.timeout,
+		isTemp:    f.temporary,
+		stack:     captureStack(),
+	}
+}
+
+// NewMetadataError creates a MetadataError, capturing the caller stack when enabled
+func NewMetadataError(op, fileID string, err error, opts ...ErrorOption) *MetadataError {
+	f := applyErrorOptions(opts)
+	return &MetadataError{
+		Op:         op,
+		FileID:     fileID,
+		Err:        err,
+		isTemp:     f.temporary,
+		retryAfter: f.retryAfter,
+		stack:      captureStack(),
+	}
+}
+
+// NewStorageError creates a StorageError, capturing the caller stack when enabled
+func NewStorageError(op, bucket, key string, err error, opts ...ErrorOption) *StorageError {
+	f := applyErrorOptions(opts)
+	return &StorageError{
+		Op:         op,
+		Bucket:     bucket,
+		Key:        key,
+		Err:        err,
+		isTimeout:  f.timeout,
+		isTemp:     f.temporary,
+		retryAfter: f.retryAfter,
+		stack:      captureStack(),
+	}
+}
+
+// NewKeyProviderError creates a KeyProviderError, capturing the caller stack when enabled
+func NewKeyProviderError(op, keyID string, err error, opts ...ErrorOption) *KeyProviderError {
+	f := applyErrorOptions(opts)
+	return &KeyProviderError{
+		Op:        op,
+		KeyID:     keyID,
+		Err:       err,
+		isTimeout: f.timeout,
+		isTemp:    f.temporary,
+		stack:     captureStack(),
+	}
+}
+
+// NewStorageQuotaError creates a StorageQuotaError wrapping ErrQuotaExceeded,
+// capturing the caller stack when enabled
+func NewStorageQuotaError(bucket string, currentUsage, limit int64) *StorageQuotaError {
+	return &StorageQuotaError{
+		Bucket:       bucket,
+		CurrentUsage: currentUsage,
+		Limit:        limit,
+		Err:          ErrQuotaExceeded,
+		stack:        captureStack(),
+	}
+}
+
+// NewIntegrityError creates an IntegrityError wrapping ErrChecksumMismatch,
+// capturing the caller stack when enabled
+func NewIntegrityError(op, bucket, key string, expected, actual Checksum) *IntegrityError {
+	return &IntegrityError{
+		Op:       op,
+		Bucket:   bucket,
+		Key:      key,
+		Expected: expected,
+		Actual:   actual,
+		Err:      ErrChecksumMismatch,
+		stack:    captureStack(),
+	}
+}
+
+// NewPermissionError creates a PermissionError wrapping ErrPermissionDenied,
+// capturing the caller stack when enabled
+func NewPermissionError(userID string, action Action, bucket string) *PermissionError {
+	return &PermissionError{
+		UserID: userID,
+		Action: action,
+		Bucket: bucket,
+		Err:    ErrPermissionDenied,
+		stack:  captureStack(),
+	}
+}
+
+// NewValidationError creates a ValidationError wrapping ErrValidationFailed,
+// capturing the caller stack when enabled
+func NewValidationError(bucket, fileName string, violations []Violation) *ValidationError {
+	return &ValidationError{
+		Bucket:     bucket,
+		FileName:   fileName,
+		Violations: violations,
+		Err:        ErrValidationFailed,
+		stack:      captureStack(),
+	}
+}
+
+// ============================================================================
+// Context Wrapping
+// ============================================================================
+
+// wrappedError is the error returned by WrapWithContext
+type wrappedError struct {
+	msg   string
+	err   error
+	stack stack
+}
+
+func (e *wrappedError) Error() string {
+	return e.msg + ": " + e.err.Error()
+}
+
+func (e *wrappedError) Unwrap() error {
+	return e.err
+}
+
+func (e *wrappedError) StackTrace() []runtime.Frame {
+	return e.stack.frames()
+}
+
+func (e *wrappedError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('#') {
+		fmt.Fprintf(s, "&propagator.wrappedError{msg:%q, err:%#v}", e.msg, e.err)
+		return
+	}
+	formatError(s, verb, e)
+}
+
+// ============================================================================
+// Chain Formatting
+// ============================================================================
+
+// writeChain writes err and everything it wraps, one error per line
+// Each line holds the error's own message, its redacted fields and, when a
+// stack was captured, the file:line where it was created or wrapped
+// Branches of joined errors are indented below the join
+func writeChain(w io.Writer, err error, depth int) {
+	indent := strings.Repeat("    ", depth)
+	prefix := ""
+	for err != nil {
+		if msg := ownMessage(err); msg != "" {
+			io.WriteString(w, indent+prefix+msg)
+			prefix = "caused by: "
+			if isPackageError(err) {
+				writeFields(w, err)
+			}
+			if st, ok := err.(interface{ location() string }); ok {
+				if loc := st.location(); loc != "" {
+					io.WriteString(w, "\n"+indent+"    at "+loc)
+				}
+			}
+			io.WriteString(w, "\n")
+		}
+
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			err = u.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, child := range u.Unwrap() {
+				writeChain(w, child, depth+1)
+			}
+			return
+		default:
+			return
+		}
+	}
+}
+
+// isPackageError reports whether err is one of the typed errors defined here
+func isPackageError(err error) bool {
+	switch err.(type) {
+	case *AuthError, *MetadataError, *StorageError, *StorageQuotaError, *IntegrityError, *PermissionError,
+		*StatusTransitionError, *ValidationError, *KeyProviderError:
+		return true
+	}
+	return false
+}
+
+func (e *AuthError) location() string             { return e.stack.location() }
+func (e *MetadataError) location() string         { return e.stack.location() }
+func (e *StorageError) location() string          { return e.stack.location() }
+func (e *StorageQuotaError) location() string     { return e.stack.location() }
+func (e *IntegrityError) location() string        { return e.stack.location() }
+func (e *PermissionError) location() string       { return e.stack.location() }
+func (e *StatusTransitionError) location() string { return e.stack.location() }
+func (e *ValidationError) location() string       { return e.stack.location() }
+func (e *KeyProviderError) location() string      { return e.stack.location() }
+func (e *wrappedError) location() string          { return e.stack.location() }
@@ -0,0 +1,8 @@
+//go:build propagator_stacks
+
+package propagator
+
+// Building with -tags propagator_stacks captures stacks from process start
+func init() {
+	captureStacks.Store(true)
+}
@@ -0,0 +1,142 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// withStackCapture enables stack capture for the duration of a test
+func withStackCapture(t *testing.T) {
+	t.Helper()
+	prev := StackCaptureEnabled()
+	SetStackCapture(true)
+	t.Cleanup(func() { SetStackCapture(prev) })
+}
+
+// ============================================================================
+// Stack Capture Tests
+// ============================================================================
+
+func TestStackCapture_Disabled(t *testing.T) {
+	prev := StackCaptureEnabled()
+	SetStackCapture(false)
+	t.Cleanup(func() { SetStackCapture(prev) })
+
+	err := NewStorageError("upload", "b", "k", ErrStorageUnavailable)
+	if frames := err.StackTrace(); frames != nil {
+		t.Errorf("expected no frames when capture is disabled, got %d", len(frames))
+	}
+	if strings.Contains(fmt.Sprintf("%+v", err), "stack_test.go") {
+		t.Errorf("%%+v should not print locations without a captured stack")
+	}
+}
+
+func TestStackCapture_RecordsCreationSite(t *testing.T) {
+	withStackCapture(t)
+
+	err := NewAuthError("validate_token", "user123", "sk-secret", ErrTokenExpired)
+
+	frames := err.StackTrace()
+	if len(frames) == 0 {
+		t.Fatal("expected frames to be captured")
+	}
+	if !strings.HasSuffix(frames[0].Function, "TestStackCapture_RecordsCreationSite") {
+		t.Errorf("first frame should be the caller of the constructor, got %s", frames[0].Function)
+	}
+}
+
+func TestWrapWithContext_RecordsWrapSite(t *testing.T) {
+	withStackCapture(t)
+
+	err := WrapWithContext(ErrAuthFailed, "layer")
+
+	var st StackTracer
+	if !errors.As(err, &st) {
+		t.Fatal("WrapWithContext result should implement StackTracer")
+	}
+	if frames := st.StackTrace(); len(frames) == 0 || !strings.HasSuffix(frames[0].Function, "TestWrapWithContext_RecordsWrapSite") {
+		t.Errorf("unexpected wrap frames: %v", frames)
+	}
+}
+
+func TestFormat_PlusVPrintsChainWithLocations(t *testing.T) {
+	withStackCapture(t)
+
+	gateway := NewCloudStorageGateway(
+		&mockAuthService{userID: "user123"},
+		&mockMetadataService{fileID: "file456"},
+		&mockStorageService{err: NewStorageError("upload", "my-bucket", "file456", context.DeadlineExceeded, AsTimeout())},
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{
+		Token:    "valid-token",
+		FileName: "test.txt",
+		Bucket:   "my-bucket",
+		Data:     []byte("hello world"),
+	})
+
+	out := fmt.Sprintf("%+v", err)
+	lines := strings.Split(out, "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected wrap, location, storage error, location, cause; got:\n%s", out)
+	}
+	if lines[0] != "upload failed: storage" {
+		t.Errorf("first line should be the wrap context, got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "(*CloudStorageGateway).") || !strings.Contains(lines[1], "(propagator.go:") {
+		t.Errorf("wrap location should point into the gateway, got %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "caused by: storage error during upload") || !strings.Contains(lines[2], "Bucket=my-bucket") {
+		t.Errorf("unexpected storage line %q", lines[2])
+	}
+	if !strings.Contains(lines[3], "TestFormat_PlusVPrintsChainWithLocations (stack_test.go:") {
+		t.Errorf("storage error location should point at its creation, got %q", lines[3])
+	}
+	if lines[4] != "caused by: context deadline exceeded" {
+		t.Errorf("unexpected root cause line %q", lines[4])
+	}
+
+	if compact := fmt.Sprintf("%v", err); strings.Contains(compact, "\n") || compact != err.Error() {
+		t.Errorf("%%v should stay compact, got %q", compact)
+	}
+}
+
+func TestFormat_PlusVIndentsJoinedBranches(t *testing.T) {
+	err := WrapWithContext(errors.Join(
+		NewStorageError("upload", "b", "k1", ErrStorageUnavailable),
+		NewMetadataError("update", "f2", ErrDatabaseDeadlock),
+	), "batch failed")
+
+	out := fmt.Sprintf("%+v", err)
+	for _, want := range []string{
+		"batch failed\n",
+		"\n    storage error during upload",
+		"\n    caused by: storage service unavailable",
+		"\n    metadata error during update",
+		"\n    caused by: database deadlock",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestConstructors_SetClassification(t *testing.T) {
+	if !IsTimeout(NewAuthError("validate_token", "", "", errors.New("slow"), AsTimeout())) {
+		t.Error("AsTimeout should mark AuthError as timeout")
+	}
+	if !IsTemporary(NewMetadataError("insert", "", ErrDatabaseDeadlock, AsTemporary())) {
+		t.Error("AsTemporary should mark MetadataError as temporary")
+	}
+	if IsTemporary(NewStorageError("upload", "", "", errors.New("bad request"))) {
+		t.Error("errors are permanent unless marked otherwise")
+	}
+
+	quotaErr := NewStorageQuotaError("b", 10, 5)
+	if !errors.Is(quotaErr, ErrQuotaExceeded) || quotaErr.CurrentUsage != 10 {
+		t.Errorf("unexpected quota error: %v", quotaErr)
+	}
+}
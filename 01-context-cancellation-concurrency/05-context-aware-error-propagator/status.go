@@ -0,0 +1,87 @@
+package propagator
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ============================================================================
+// File Status
+// ============================================================================
+
+// FileStatus is the lifecycle state of a file record
+type FileStatus string
+
+// File statuses, in the order an upload moves through them
+const (
+	StatusPending   FileStatus = "pending"   // Record created, no content yet
+	StatusUploading FileStatus = "uploading" // Content is being stored
+	StatusCompleted FileStatus = "completed" // Content stored and verified
+	StatusFailed    FileStatus = "failed"    // Upload gave up; the record is kept for inspection
+	StatusDeleted   FileStatus = "deleted"   // Object removed; the record is a tombstone until deleted
+)
+
+// transitions lists the statuses each status may change to
+// completed and failed are final for an upload, and deleted is final for good,
+// so a late compensation can never turn a completed file into a failed one
+var transitions = map[FileStatus][]FileStatus{
+	StatusPending:   {StatusUploading, StatusFailed, StatusDeleted},
+	StatusUploading: {StatusCompleted, StatusFailed},
+	StatusCompleted: {StatusDeleted},
+	StatusFailed:    {StatusDeleted},
+	StatusDeleted:   nil,
+}
+
+// Valid reports whether s is one of the defined statuses
+func (s FileStatus) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether a record in status s may change to next
+// Staying in the same status is always allowed, so repeated updates are idempotent
+func (s FileStatus) CanTransitionTo(next FileStatus) bool {
+	if !s.Valid() || !next.Valid() {
+		return false
+	}
+	return s == next || slices.Contains(transitions[s], next)
+}
+
+// StatusTransitionError reports a status change the state machine does not allow
+type StatusTransitionError struct {
+	From  FileStatus
+	To    FileStatus
+	Err   error
+	stack stack
+}
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Errorf("status transition error: %s -> %s: %w", e.From, e.To, e.Err).Error()
+}
+
+func (e *StatusTransitionError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary always returns false: the same change would be refused again
+func (e *StatusTransitionError) Temporary() bool {
+	return false
+}
+
+// ErrInvalidStatusTransition is wrapped by every StatusTransitionError
+var ErrInvalidStatusTransition = NewSentinel(CodeInvalidStatusTransition, "invalid file status transition")
+
+// CheckTransition returns nil if a record may move from one status to another
+// Otherwise it returns a StatusTransitionError wrapping ErrInvalidStatusTransition.
+// Metadata backends call it before applying UpdateFileStatus
+func CheckTransition(from, to FileStatus) error {
+	if from.CanTransitionTo(to) {
+		return nil
+	}
+	return &StatusTransitionError{
+		From:  from,
+		To:    to,
+		Err:   ErrInvalidStatusTransition,
+		stack: captureStack(),
+	}
+}
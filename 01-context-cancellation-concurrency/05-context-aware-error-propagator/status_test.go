@@ -0,0 +1,112 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// ============================================================================
+// State Machine Tests
+// ============================================================================
+
+func TestFileStatus_CanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to FileStatus
+		want     bool
+	}{
+		{from: StatusPending, to: StatusUploading, want: true},
+		{from: StatusPending, to: StatusFailed, want: true},
+		{from: StatusPending, to: StatusDeleted, want: true},
+		{from: StatusUploading, to: StatusCompleted, want: true},
+		{from: StatusUploading, to: StatusFailed, want: true},
+		{from: StatusCompleted, to: StatusDeleted, want: true},
+		{from: StatusFailed, to: StatusDeleted, want: true},
+		{from: StatusCompleted, to: StatusCompleted, want: true},
+		{from: StatusPending, to: StatusCompleted},
+		{from: StatusUploading, to: StatusDeleted},
+		{from: StatusCompleted, to: StatusFailed},
+		{from: StatusFailed, to: StatusCompleted},
+		{from: StatusDeleted, to: StatusCompleted},
+		{from: StatusCompleted, to: StatusPending},
+		{from: "archived", to: "archived"},
+		{from: StatusPending, to: "archived"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Errorf("CanTransitionTo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTransition(t *testing.T) {
+	if err := CheckTransition(StatusUploading, StatusCompleted); err != nil {
+		t.Errorf("expected an allowed transition, got: %v", err)
+	}
+
+	err := WrapWithContext(NewMetadataError("update", "file456", CheckTransition(StatusCompleted, StatusFailed)), "upload failed")
+
+	var transitionErr *StatusTransitionError
+	if !errors.As(err, &transitionErr) || transitionErr.From != StatusCompleted || transitionErr.To != StatusFailed {
+		t.Fatalf("expected StatusTransitionError completed -> failed, got: %v", err)
+	}
+	if !errors.Is(err, ErrInvalidStatusTransition) || IsTemporary(err) {
+		t.Errorf("expected a permanent ErrInvalidStatusTransition, got: %v", err)
+	}
+	if Code(err) != CodeInvalidStatusTransition {
+		t.Errorf("Code() = %s, want %s", Code(err), CodeInvalidStatusTransition)
+	}
+}
+
+func TestStatusTransitionError_LogValue(t *testing.T) {
+	var buf strings.Builder
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	logger.Error("refused", "error", CheckTransition(StatusDeleted, StatusCompleted))
+
+	for _, want := range []string{"error.code=METADATA_INVALID_STATUS_TRANSITION", "error.from=deleted", "error.to=completed", "error.temporary=false"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log output missing %q: %s", want, buf.String())
+		}
+	}
+}
+
+// ============================================================================
+// Gateway Status Tests
+// ============================================================================
+
+func TestCloudStorageGateway_UploadFile_EmitsStatuses(t *testing.T) {
+	metadata := &syncMetadataService{mockMetadataService: mockMetadataService{fileID: "file456"}}
+	gateway := NewCloudStorageGateway(&mockAuthService{userID: "user123"}, metadata, &mockStorageService{})
+
+	if err := gateway.UploadFile(context.Background(), FileUploadRequest{Token: "valid-token", FileName: "test.txt", Bucket: "my-bucket", Data: []byte("hello")}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !slices.Equal(metadata.statuses, []FileStatus{StatusUploading, StatusCompleted}) {
+		t.Errorf("expected uploading then completed, got %v", metadata.statuses)
+	}
+}
+
+func TestCloudStorageGateway_DeleteFile_RefusesUploadInProgress(t *testing.T) {
+	store := newMemFileStore()
+	gateway := NewCloudStorageGateway(&countingAuthService{}, store, store)
+	fileID := uploadTestFile(t, gateway, "alice", []byte("hello world"))
+	rec := store.records[fileID]
+	rec.Status = StatusUploading
+	store.records[fileID] = rec
+
+	err := gateway.DeleteFile(context.Background(), "alice", fileID)
+
+	var transitionErr *StatusTransitionError
+	if !errors.As(err, &transitionErr) || transitionErr.From != StatusUploading {
+		t.Fatalf("expected StatusTransitionError from uploading, got: %v", err)
+	}
+	if len(store.objects) != 1 || store.records[fileID].Status != StatusUploading {
+		t.Error("a refused delete must leave the object and record alone")
+	}
+}
@@ -0,0 +1,171 @@
+package propagator
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// ============================================================================
+// Upload Validation
+// ============================================================================
+
+// MaxFileNameLength is the longest file name, in bytes, the gateway accepts
+const MaxFileNameLength = 255
+
+// sniffLen is how much content http.DetectContentType looks at
+const sniffLen = 512
+
+// Rules checked by the validation stage
+const (
+	RuleFileName  = "file_name"
+	RuleMaxSize   = "max_size"
+	RuleExtension = "extension"
+	RuleMIMEType  = "mime_type"
+)
+
+// UploadRules constrain what may be uploaded to a bucket
+// Zero values impose no constraint
+type UploadRules struct {
+	MaxSize           int64    // Largest accepted Data, in bytes
+	AllowedExtensions []string // File name extensions such as ".jpg", matched case-insensitively
+	AllowedMIMETypes  []string // Sniffed media types such as "image/png"; "image/*" allows a whole type
+}
+
+// Violation is one rule an upload broke
+// Message never contains the request content, so it is safe to show to clients
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+// ValidationError reports every rule an upload request broke
+type ValidationError struct {
+	Bucket     string
+	FileName   string
+	Violations []Violation
+	Err        error
+	stack      stack
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Rule + ": " + v.Message
+	}
+	return fmt.Errorf("validation error: %q in bucket %s: %s: %w", e.FileName, e.Bucket, strings.Join(msgs, "; "), e.Err).Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary always returns false: the same request would be rejected again
+func (e *ValidationError) Temporary() bool {
+	return false
+}
+
+// Violated reports whether rule is among the violations
+func (e *ValidationError) Violated(rule string) bool {
+	return slices.ContainsFunc(e.Violations, func(v Violation) bool { return v.Rule == rule })
+}
+
+// ErrValidationFailed is wrapped by every ValidationError
+var ErrValidationFailed = NewSentinel(CodeValidationFailed, "upload validation failed")
+
+// WithUploadRules applies rules to uploads into bucket
+// Rules registered for AllBuckets apply to buckets without rules of their own
+func WithUploadRules(bucket string, rules UploadRules) Option {
+	return func(g *CloudStorageGateway) {
+		if g.uploadRules == nil {
+			g.uploadRules = make(map[string]UploadRules)
+		}
+		g.uploadRules[bucket] = rules
+	}
+}
+
+// validateUpload sanitizes the file name and checks the request against the bucket's rules
+// It returns the sanitized name, or a ValidationError listing every violation
+func (g *CloudStorageGateway) validateUpload(req FileUploadRequest) (string, error) {
+	name := SanitizeFileName(req.FileName)
+	var violations []Violation
+	switch {
+	case name == "":
+		violations = append(violations, Violation{Rule: RuleFileName, Message: "file name is empty"})
+	case len(name) > MaxFileNameLength:
+		violations = append(violations, Violation{Rule: RuleFileName, Message: fmt.Sprintf("file name is longer than %d bytes", MaxFileNameLength)})
+	}
+
+	rules, ok := g.uploadRules[req.Bucket]
+	if !ok {
+		rules = g.uploadRules[AllBuckets]
+	}
+	if rules.MaxSize > 0 && int64(len(req.Data)) > rules.MaxSize {
+		violations = append(violations, Violation{Rule: RuleMaxSize, Message: fmt.Sprintf("file is %d bytes, the limit is %d", len(req.Data), rules.MaxSize)})
+	}
+	if len(rules.AllowedExtensions) > 0 {
+		ext := strings.ToLower(path.Ext(name))
+		allowed := slices.ContainsFunc(rules.AllowedExtensions, func(a string) bool {
+			return ext != "" && strings.EqualFold("."+strings.TrimPrefix(a, "."), ext)
+		})
+		if !allowed {
+			violations = append(violations, Violation{Rule: RuleExtension, Message: fmt.Sprintf("extension %q is not allowed", ext)})
+		}
+	}
+	if len(rules.AllowedMIMETypes) > 0 {
+		mediaType := sniffMediaType(req.Data)
+		if !slices.ContainsFunc(rules.AllowedMIMETypes, func(a string) bool { return matchMediaType(a, mediaType) }) {
+			violations = append(violations, Violation{Rule: RuleMIMEType, Message: fmt.Sprintf("content type %s is not allowed", mediaType)})
+		}
+	}
+
+	if len(violations) > 0 {
+		return "", NewValidationError(req.Bucket, name, violations)
+	}
+	return name, nil
+}
+
+// SanitizeFileName reduces name to a plain file name
+// Directory components (with either slash), control characters and invalid
+// UTF-8 are removed and surrounding spaces trimmed. Names that are nothing
+// but dots come back empty
+func SanitizeFileName(name string) string {
+	name = strings.ToValidUTF8(name, "")
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSpace(name)
+	if strings.Trim(name, ".") == "" {
+		return ""
+	}
+	return name
+}
+
+// sniffMediaType returns the media type of data without parameters, such as "text/plain"
+func sniffMediaType(data []byte) string {
+	contentType := http.DetectContentType(data[:min(len(data), sniffLen)])
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return mediaType
+}
+
+// matchMediaType reports whether mediaType is allowed by pattern, which may end in "/*"
+func matchMediaType(pattern, mediaType string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(mediaType, prefix+"/")
+	}
+	return pattern == mediaType
+}
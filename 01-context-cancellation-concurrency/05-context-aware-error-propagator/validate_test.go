@@ -0,0 +1,140 @@
+package propagator
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// pngHeader is enough of a PNG file for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// ============================================================================
+// File Name Tests
+// ============================================================================
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "report.pdf", want: "report.pdf"},
+		{name: "../../etc/passwd", want: "passwd"},
+		{name: `C:\Users\me\photo.JPG`, want: "photo.JPG"},
+		{name: "  spaced name.txt  ", want: "spaced name.txt"},
+		{name: "evil\x00name\n.txt", want: "evilname.txt"},
+		{name: "bad\xffutf8.txt", want: "badutf8.txt"},
+		{name: "dir/", want: ""},
+		{name: "..", want: ""},
+		{name: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeFileName(tt.name); got != tt.want {
+				t.Errorf("SanitizeFileName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+// ============================================================================
+// Gateway Validation Tests
+// ============================================================================
+
+func TestCloudStorageGateway_UploadFile_ReportsEveryViolation(t *testing.T) {
+	metadata := &countingMetadataService{mockMetadataService: mockMetadataService{fileID: "file456"}}
+	gateway := NewCloudStorageGateway(&mockAuthService{userID: "user123"}, metadata, &mockStorageService{},
+		WithUploadRules("images", UploadRules{MaxSize: 10, AllowedExtensions: []string{"png", ".jpg"}, AllowedMIMETypes: []string{"image/*"}}),
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{Token: "valid-token", FileName: "notes.txt", Bucket: "images", Data: []byte("plain text that is too long")})
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected ValidationError, got: %v", err)
+	}
+	for _, rule := range []string{RuleMaxSize, RuleExtension, RuleMIMEType} {
+		if !validationErr.Violated(rule) {
+			t.Errorf("expected a %s violation, got %+v", rule, validationErr.Violations)
+		}
+	}
+	if !errors.Is(err, ErrValidationFailed) || IsTemporary(err) || Code(err) != CodeValidationFailed {
+		t.Errorf("expected a permanent ErrValidationFailed, got: %v (code %s)", err, Code(err))
+	}
+	if metadata.creates != 0 {
+		t.Error("validation must run before any metadata is written")
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_AcceptsAllowedContent(t *testing.T) {
+	storage := &mockStorageService{}
+	metadata := &captureMetadataService{mockMetadataService: mockMetadataService{fileID: "file456"}}
+	gateway := NewCloudStorageGateway(&mockAuthService{userID: "user123"}, metadata, storage,
+		WithUploadRules("images", UploadRules{MaxSize: 1024, AllowedExtensions: []string{".png"}, AllowedMIMETypes: []string{"image/png"}}),
+	)
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{Token: "valid-token", FileName: "../avatars/Me.PNG", Bucket: "images", Data: pngHeader})
+
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if metadata.created.FileName != "Me.PNG" {
+		t.Errorf("the sanitized name should be stored, got %q", metadata.created.FileName)
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_DefaultRules(t *testing.T) {
+	gateway := NewCloudStorageGateway(&mockAuthService{userID: "user123"}, &mockMetadataService{fileID: "file456"}, &mockStorageService{},
+		WithUploadRules(AllBuckets, UploadRules{MaxSize: 4}),
+		WithUploadRules("big", UploadRules{}),
+	)
+	req := FileUploadRequest{Token: "valid-token", FileName: "a.txt", Bucket: "other", Data: []byte("hello")}
+
+	if err := gateway.UploadFile(context.Background(), req); !errors.Is(err, ErrValidationFailed) {
+		t.Errorf("AllBuckets rules should apply to other buckets, got: %v", err)
+	}
+	req.Bucket = "big"
+	if err := gateway.UploadFile(context.Background(), req); err != nil {
+		t.Errorf("bucket rules should replace the defaults, got: %v", err)
+	}
+}
+
+func TestCloudStorageGateway_UploadFile_RejectsEmptyFileName(t *testing.T) {
+	gateway := NewCloudStorageGateway(&mockAuthService{userID: "user123"}, &mockMetadataService{fileID: "file456"}, &mockStorageService{})
+
+	err := gateway.UploadFile(context.Background(), FileUploadRequest{Token: "valid-token", FileName: "../", Bucket: "my-bucket", Data: []byte("hello")})
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || !validationErr.Violated(RuleFileName) {
+		t.Errorf("expected a file name violation, got: %v", err)
+	}
+}
+
+func TestValidationError_LogValue(t *testing.T) {
+	var buf strings.Builder
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	logger.Error("rejected", "error", NewValidationError("images", "a.exe", []Violation{
+		{Rule: RuleExtension, Message: "extension \".exe\" is not allowed"},
+		{Rule: RuleMaxSize, Message: "file is 11 bytes, the limit is 10"},
+	}))
+
+	for _, want := range []string{"error.code=VALIDATION_FAILED", "error.bucket=images", "error.rules=extension,max_size"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log output missing %q: %s", want, buf.String())
+		}
+	}
+}
+
+// captureMetadataService remembers the last record it was asked to create
+type captureMetadataService struct {
+	mockMetadataService
+	created FileRecord
+}
+
+func (m *captureMetadataService) CreateFileRecord(ctx context.Context, rec FileRecord) (string, error) {
+	m.created = rec
+	return m.mockMetadataService.CreateFileRecord(ctx, rec)
+}